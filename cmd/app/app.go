@@ -1,62 +1,130 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux" // in order to proccess different types of requests
 )
 
 type App struct {
 	Router *mux.Router
-	DB     *sql.DB
+	Store  Store
 }
 
-// Initialize mysql with login credentials (user, password) and database name (dbname)
-func (a *App) Init(user string, password string, host string, port string, dbname string) {
+// Initialize the App with the storage backend (store) used by the handlers
+func (a *App) Init(store Store) {
 
-	var err error
+	a.Store = store
+
+	//mux
+	a.Router = mux.NewRouter()
+
+	a.initializeRoutes()
+}
+
+// Runs the App on the configured address (cfg.ListenAddr) until SIGINT or SIGTERM
+func (a *App) Run(cfg Config) {
 
-	//initialize DB
-	//"user:password@/database?parseTime=true"
-	dataSource := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, dbname)
+	// stop on SIGINT (ctrl+c) and SIGTERM (docker stop, deploys)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	a.DB, err = sql.Open("mysql", dataSource)
+	l, err := net.Listen("tcp", cfg.ListenAddr)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	//mux
-	a.Router = mux.NewRouter()
-
-	a.initializeRoutes()
+	if err := a.serve(ctx, l, cfg); err != nil {
+		log.Fatal(err)
+	}
 }
 
-// Runs the App on adress (addr)
-func (a *App) Run(addr string) {
-	err := http.ListenAndServe(addr, a.Router)
+// Serves requests on listener (l) until ctx is done, then drains in-flight requests
+// for up to cfg.ShutdownTimeout and closes the store
+func (a *App) serve(ctx context.Context, l net.Listener, cfg Config) error {
+	server := &http.Server{
+		Handler:           a.Router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration, // slow clients can't hold connections while sending headers
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(l)
+	}()
 
+	select {
+	case err := <-errs: // server failed, nothing to drain
+		a.Store.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	// stops accepting connections and waits for active requests to finish
+	err := server.Shutdown(shutdownCtx)
 	if err != nil {
-		log.Fatal(err)
+		server.Close() // deadline exceeded, drop the remaining connections
+	}
+
+	// the pool is closed once no handler can use it anymore
+	if closeErr := a.Store.Close(); err == nil {
+		err = closeErr
 	}
+
+	return err
 }
 
 // Initialize routing
 func (a *App) initializeRoutes() {
 
-	a.Router.HandleFunc("/guest_list/{name}", a.handlerAddGuest).Methods("POST")  // Add a guest to the guestlist "POST /guest_list/name"
-	a.Router.HandleFunc("/guest_list", a.handlerGuestList).Methods("GET")         // Get the guest list "GET /guest_list"
-	a.Router.HandleFunc("/guests/{name}", a.handlerGuestArrives).Methods("PUT")   // Guest Arrives "PUT /guests/name"
-	a.Router.HandleFunc("/guests/{name}", a.handlerGuestLeaves).Methods("DELETE") // Guest Leaves "DELETE /guests/name"
-	a.Router.HandleFunc("/guests", a.handlerArrivedGuests).Methods("GET")         // Get arrived guests "GET /guests"
-	a.Router.HandleFunc("/seats_empty", a.handlerSeatsEmpty).Methods("GET")       // Count number of empty seats "GET /seats_empty"
-	a.Router.HandleFunc("/guests/{name}", a.handlerGetGuest).Methods("GET")       // Gets guest info "GET /guests/name"
-	a.Router.HandleFunc("/venue", a.handlerAddTable).Methods("POST")              // Adds table to venue "POST /venue"
+	a.Router.HandleFunc("/guest_list/{name}", a.handlerAddGuest).Methods("POST")                // Add a guest to the guestlist "POST /guest_list/name"
+	a.Router.HandleFunc("/guest_list/{name}", a.handlerUninviteGuest).Methods("DELETE")         // Remove a guest from the guestlist "DELETE /guest_list/name"
+	a.Router.HandleFunc("/guest_list", a.handlerGuestList).Methods("GET")                       // Get the guest list "GET /guest_list"
+	a.Router.HandleFunc("/guests/{name}", a.handlerGuestArrives).Methods("PUT")                 // Guest Arrives "PUT /guests/name"
+	a.Router.HandleFunc("/guests/{name}", a.handlerGuestLeaves).Methods("DELETE")               // Guest Leaves "DELETE /guests/name"
+	a.Router.HandleFunc("/guests/{name}/check_in", a.handlerCheckIn).Methods("POST")            // People of a party come in "POST /guests/name/check_in"
+	a.Router.HandleFunc("/guests/{name}/check_out", a.handlerCheckOut).Methods("POST")          // People of a party go out "POST /guests/name/check_out"
+	a.Router.HandleFunc("/guests/{name}/timeline", a.handlerTimeline).Methods("GET")            // Guest's movements "GET /guests/name/timeline"
+	a.Router.HandleFunc("/guests/{name}/move", a.handlerMoveGuest).Methods("POST")              // Moves or swaps a party "POST /guests/name/move"
+	a.Router.HandleFunc("/guests", a.handlerArrivedGuests).Methods("GET")                       // Get arrived guests "GET /guests"
+	a.Router.HandleFunc("/seats_empty", a.handlerSeatsEmpty).Methods("GET")                     // Count number of empty seats "GET /seats_empty"
+	a.Router.HandleFunc("/guests/{name}", a.handlerGetGuest).Methods("GET")                     // Gets guest info "GET /guests/name"
+	a.Router.HandleFunc("/venue", a.handlerAddTable).Methods("POST")                            // Adds table to venue "POST /venue"
+	a.Router.HandleFunc("/venue", a.handlerGetTables).Methods("GET")                            // Lists tables "GET /venue"
+	a.Router.HandleFunc("/venue/occupancy", a.handlerOccupancy).Methods("GET")                  // Seats and guests per table "GET /venue/occupancy"
+	a.Router.HandleFunc("/venue/plan", a.handlerPlanSeating).Methods("POST")                    // Plans (and applies) seating under constraints "POST /venue/plan"
+	a.Router.HandleFunc("/venue/fits", a.handlerFits).Methods("GET")                            // Tables that can take a party "GET /venue/fits?party_size=int"
+	a.Router.HandleFunc("/venue/{table:[0-9]+}", a.handlerGetTable).Methods("GET")              // Gets table info "GET /venue/table"
+	a.Router.HandleFunc("/venue/{table:[0-9]+}", a.handlerResizeTable).Methods("PATCH")         // Changes table seats "PATCH /venue/table"
+	a.Router.HandleFunc("/venue/{table:[0-9]+}", a.handlerDeleteTable).Methods("DELETE")        // Removes table "DELETE /venue/table"
+	a.Router.HandleFunc("/v2/guests", a.handlerInviteGuest).Methods("POST")                     // Adds a guest, names may repeat "POST /v2/guests"
+	a.Router.HandleFunc("/v2/guests", a.handlerFindGuests).Methods("GET")                       // Searches guests by name "GET /v2/guests?name=string"
+	a.Router.HandleFunc("/v2/guests/import", a.handlerImportGuests).Methods("POST")             // Adds many guests from JSON or CSV "POST /v2/guests/import"
+	a.Router.HandleFunc("/v2/guests/{id:[0-9]+}", a.handlerGetGuestByID).Methods("GET")         // Gets guest info "GET /v2/guests/id"
+	a.Router.HandleFunc("/v2/guests/{id:[0-9]+}", a.handlerEditGuest).Methods("PATCH")          // Renames or moves a guest "PATCH /v2/guests/id"
+	a.Router.HandleFunc("/v2/guests/{id:[0-9]+}", a.handlerDeleteGuestByID).Methods("DELETE")   // Removes a guest "DELETE /v2/guests/id"
+	a.Router.HandleFunc("/v2/guests/{id:[0-9]+}/move", a.handlerMoveGuestByID).Methods("POST")  // Moves or swaps a party "POST /v2/guests/id/move"
+	a.Router.HandleFunc("/waitlist", a.handlerGetWaitlist).Methods("GET")                       // Lists waiting (or promoted) guests "GET /waitlist"
+	a.Router.HandleFunc("/waitlist/{id:[0-9]+}", a.handlerRemoveFromWaitlist).Methods("DELETE") // Drops a waiting guest "DELETE /waitlist/id"
+	a.Router.HandleFunc("/healthz", a.handlerHealthz).Methods("GET")                            // Liveness "GET /healthz"
+	a.Router.HandleFunc("/readyz", a.handlerReadyz).Methods("GET")                              // Readiness "GET /readyz"
 }
 
 // Sends JSON responses
@@ -75,17 +143,57 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
-// Sends error response
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+// HTTP status of each kind of model error
+var errorStatuses = map[ErrorKind]int{
+	ErrorInvalid:  http.StatusBadRequest,
+	ErrorNotFound: http.StatusNotFound,
+	ErrorConflict: http.StatusConflict,
+}
+
+// Sends err as a problem (RFC 7807), with the status and code of its model Error
+// Any other error is unexpected, most likely from the database: it's logged and answered as internal_error without its details
+func respondWithProblem(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		log.Printf("internal error: %v", err)
+		e = &Error{Code: "internal_error", Message: "internal error"}
+	}
+
+	status, ok := errorStatuses[e.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	response, _ := json.Marshal(Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: e.Message, Code: e.Code, Param: e.Param})
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// Table assignment strategy from ?strategy=, best_fit if not given
+func requestStrategy(r *http.Request) (Strategy, bool) {
+	name := r.URL.Query().Get("strategy")
+	if name == "" {
+		name = "best_fit"
+	}
+
+	pick, ok := strategies[name]
+
+	return pick, ok
 }
 
 /*
 ### Add a guest to the guestlist
 
 If there is insufficient space at the specified table, throws an error (http.StatusConflict).
+Without a table, the service picks one with enough free seats, following ?strategy=:
+best_fit (default, the table the party fills the most), first_fit (the lowest numbered table) or balanced (the least occupied table).
+The chosen table_number is part of the response then.
+With ?waitlist=true a guest turned away for lack of seats is queued on the waitlist instead (http.StatusAccepted, with the entry),
+?priority=int orders the queue (higher first, 0 if not given). See GET /waitlist.
 
-POST /guest_list/name
+POST /guest_list/name?strategy=best_fit|first_fit|balanced&waitlist=true&priority=int
 body:
 {
     "table": int,
@@ -93,7 +201,8 @@ body:
 }
 response:
 {
-    "name": "string"
+    "name": "string",
+	"table_number": int
 }
 */
 func (a *App) handlerAddGuest(w http.ResponseWriter, r *http.Request) {
@@ -104,16 +213,54 @@ func (a *App) handlerAddGuest(w http.ResponseWriter, r *http.Request) {
 	// Decoding request body into Guest struct
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&g); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithProblem(w, invalidPayload(err))
 		return
 	}
 	defer r.Body.Close()
 
 	g.Name = name
 
+	if err := validGuest(g); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	queue, priority, ok := requestWaitlist(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("priority", "priority must be a number"))
+		return
+	}
+
+	// No table, the service picks one
+	if g.Table == 0 {
+		pick, ok := requestStrategy(r)
+		if !ok {
+			respondWithProblem(w, invalidParam("strategy", "strategy must be best_fit, first_fit or balanced"))
+			return
+		}
+
+		if err := a.Store.AssignGuest(&g, pick, true); err != nil {
+			if errors.Is(err, ErrTableFull) && queue {
+				a.queueGuest(w, Guest{Name: g.Name, AccompanyingGuests: g.AccompanyingGuests}, priority, true)
+				return
+			}
+
+			respondWithProblem(w, err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{"name": g.Name, "table_number": g.Table})
+		return
+	}
+
 	// Adding guest to guest list
-	if err := g.addGuest(a.DB); err != nil {
-		respondWithError(w, http.StatusConflict, err.Error())
+	if err := a.Store.AddGuest(&g); err != nil {
+		if errors.Is(err, ErrTableFull) && queue {
+			a.queueGuest(w, g, priority, true)
+			return
+		}
+
+		respondWithProblem(w, err)
 		return
 	}
 
@@ -123,7 +270,19 @@ func (a *App) handlerAddGuest(w http.ResponseWriter, r *http.Request) {
 /*
 ### Get the guest list
 
-GET /guest_list
+Also exported as CSV, JSON Lines or a printable per-table HTML seating sheet, chosen with ?format=
+or the Accept header (text/csv, application/x-ndjson, text/html). Unknown formats are an error (http.StatusBadRequest).
+
+Any of these parameters pages the list instead:
+table, arrived=true|false, name_prefix, min_party and max_party (guest and accompanying guests), arrived_after (RFC 3339),
+sort=id|name|table|time_arrived (id by default, guests yet to arrive last on time_arrived) and limit (100 by default, up to 1000).
+Pages have every guest's details (id, arrival and departure too), and only pages have total (guests matching the filters)
+and next_cursor (to send as ?cursor= for the next page, with the same sort; missing on the last page).
+
+The whole list (and the CSV and JSON Lines exports) is streamed as guests are read, however long it is.
+
+GET /guest_list?format=json|csv|jsonl|html
+GET /guest_list?table=int&arrived=bool&name_prefix=string&min_party=int&max_party=int&arrived_after=string&sort=string&limit=int&cursor=string
 response:
 {
     "guests": [
@@ -132,21 +291,37 @@ response:
             "table": int,
             "accompanying_guests": int
         }, ...
-    ]
+    ],
+    "total": int,
+    "next_cursor": "string"
 }
 */
 func (a *App) handlerGuestList(w http.ResponseWriter, r *http.Request) {
 
-	var g GuestList
-	var err error
+	format, ok := exportFormat(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("format", "format must be json, csv, jsonl or html"))
+		return
+	}
 
-	// Get all guests from guestlist
-	if g, err = getGuestList(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if format != formatJSON {
+		a.exportGuests(w, format, false, "guest_list", "Guest list")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, g)
+	q, paged, err := requestGuestQuery(r)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	if paged {
+		a.respondWithGuestPage(w, q)
+		return
+	}
+
+	// the whole list, streamed as it's read
+	a.streamGuests(w, false, "application/json", &guestListEncoder{fields: listedGuest})
 
 }
 
@@ -156,6 +331,9 @@ HandlerGuestArrives () handles the PUT /guest/name requests
 
 A guest may arrive with an entourage that is not the size indicated at the guest list.
 If the table is expected to have space for the extras, allow them to come. Otherwise, this method throws an error (http.StatusConflict).
+Throws http.StatusNotFound for unknown guests.
+A guest who left may come back the same way, as long as their table still has seats for them.
+Seats left by a smaller entourage go to the waitlist.
 
 
 PUT /guests/name
@@ -176,19 +354,26 @@ func (a *App) handlerGuestArrives(w http.ResponseWriter, r *http.Request) {
 	// Decoding request body into Guest struct
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&g); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		respondWithProblem(w, invalidPayload(err))
 		return
 	}
 	defer r.Body.Close()
 
 	g.Name = name
 
+	if err := validGuest(g); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
 	// Updating guest arrived time/arrived flag on the database
-	if err := g.updateGuest(a.DB); err != nil {
-		respondWithError(w, http.StatusConflict, err.Error())
+	if err := a.Store.UpdateGuest(&g); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 
+	a.promoteWaitlist()
+
 	respondWithJSON(w, http.StatusOK, map[string]string{"name": name})
 }
 
@@ -196,6 +381,8 @@ func (a *App) handlerGuestArrives(w http.ResponseWriter, r *http.Request) {
 ### Guest Leaves
 
 When a guest leaves, all their accompanying guests leave as well.
+The guest stays on the guest list with time_left set and their seats are free again.
+Throws http.StatusNotFound for unknown guests and http.StatusConflict if the guest isn't at the venue.
 
 DELETE /guests/name
 */
@@ -203,19 +390,47 @@ func (a *App) handlerGuestLeaves(w http.ResponseWriter, r *http.Request) {
 
 	name := mux.Vars(r)["name"] // Get guest name
 
+	// Recording the departure
+	if err := a.Store.DepartGuest(name); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.promoteWaitlist()
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+/*
+### Remove a guest from the guestlist
+
+The invitation is gone, along with the guest's arrival and departure times.
+Throws http.StatusNotFound for unknown guests.
+
+DELETE /guest_list/name
+*/
+func (a *App) handlerUninviteGuest(w http.ResponseWriter, r *http.Request) {
+
+	name := mux.Vars(r)["name"] // Get guest name
+
 	// Deleting guest by name
-	if err := deleteGuest(a.DB, name); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if err := a.Store.DeleteGuest(name); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 
+	a.promoteWaitlist()
+
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
 /*
 ### Get arrived guests
 
-GET /guests
+Exported like the guest list with ?format= or the Accept header, and paged with the same parameters (arrived is always true).
+Unpaged, it is streamed like the guest list.
+
+GET /guests?format=json|csv|jsonl|html
 response:
 {
     "guests": [
@@ -229,16 +444,32 @@ response:
 */
 func (a *App) handlerArrivedGuests(w http.ResponseWriter, r *http.Request) {
 
-	var g GuestList
-	var err error
+	format, ok := exportFormat(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("format", "format must be json, csv, jsonl or html"))
+		return
+	}
 
-	// Get all guests from guestlist
-	if g, err = getArrivedGuests(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if format != formatJSON {
+		a.exportGuests(w, format, true, "arrivals", "Arrivals")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, g)
+	q, paged, err := requestGuestQuery(r)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	if paged {
+		arrived := true
+		q.Arrived = &arrived
+		a.respondWithGuestPage(w, q)
+		return
+	}
+
+	// the whole list, streamed as it's read
+	a.streamGuests(w, true, "application/json", &guestListEncoder{fields: arrivedGuest})
 
 }
 
@@ -257,8 +488,8 @@ func (a *App) handlerSeatsEmpty(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	// Get empty seats
-	if s.Seats, err = getFreeSeats(a.DB, 0, true); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if s.Seats, err = a.Store.GetFreeSeats(0, true); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 
@@ -274,10 +505,14 @@ func (a *App) handlerSeatsEmpty(w http.ResponseWriter, r *http.Request) {
 GET /guests/name
 response:
 {
+	"id": int,
     "name": "string",
     "table": int,
     "accompanying_guests": int,
-	"arrived": bool
+	"time_arrived": "string",
+	"arrived": bool,
+	"time_left": "string",
+	"present": int
 }
 */
 func (a *App) handlerGetGuest(w http.ResponseWriter, r *http.Request) {
@@ -288,8 +523,8 @@ func (a *App) handlerGetGuest(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	// Get all guests from guestlist
-	if g, err = getGuest(a.DB, name); err != nil {
-		respondWithError(w, http.StatusNotFound, err.Error())
+	if g, err = a.Store.GetGuest(name); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 
@@ -301,6 +536,8 @@ func (a *App) handlerGetGuest(w http.ResponseWriter, r *http.Request) {
 
 ***NEW useful endpoint***
 
+Tables need at least a seat, otherwise throws an error (http.StatusBadRequest).
+
 POST /venue
 
 body:
@@ -318,16 +555,76 @@ func (a *App) handlerAddTable(w http.ResponseWriter, r *http.Request) {
 	// Decoding request into Guest struct
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&seats); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithProblem(w, invalidPayload(err))
 		return
 	}
 	defer r.Body.Close()
 
+	if seats.S <= 0 {
+		respondWithProblem(w, invalidParam("seats", "seats must be a positive number"))
+		return
+	}
+
 	// Adding new table
-	if err := addTable(a.DB, seats.S); err != nil {
-		respondWithError(w, http.StatusConflict, err.Error())
+	if err := a.Store.AddTable(seats.S); err != nil {
+		respondWithProblem(w, err)
 		return
 	}
 
+	a.promoteWaitlist()
+
 	respondWithJSON(w, http.StatusCreated, map[string]string{"result": "success"})
 }
+
+/*
+### Liveness
+
+The process is up and serving requests.
+
+GET /healthz
+response:
+{
+	"status": "ok"
+}
+*/
+func (a *App) handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+/*
+### Readiness
+
+The database is reachable and its schema is at the version this build expects.
+Otherwise responds with http.StatusServiceUnavailable, status unavailable and the code database_unreachable or schema_not_current
+(the details are logged).
+
+GET /readyz
+response:
+{
+	"status": "ready|unavailable",
+	"code": "string"
+}
+*/
+func (a *App) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	// the driver's errors are logged, not answered
+	if err := a.Store.Ping(ctx); err != nil {
+		log.Printf("readyz: database unreachable: %v", err)
+		respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "code": "database_unreachable"})
+		return
+	}
+
+	// SQL backends must be fully migrated
+	if s, ok := a.Store.(*sqlStore); ok {
+		if err := s.checkSchemaCurrent(); err != nil {
+			log.Printf("readyz: %v", err)
+			respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "code": "schema_not_current"})
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,112 @@
+// app_test.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Store that records whether it was closed
+type closeRecorder struct {
+	*memoryStore
+	closed bool
+}
+
+func (s *closeRecorder) Close() error {
+	s.closed = true
+	return nil
+}
+
+// Tests in-flight requests finish when the server shuts down, and the store is closed afterwards
+func TestServeGracefulShutdown(t *testing.T) {
+	store := &closeRecorder{memoryStore: newMemoryStore()}
+
+	app := App{Router: mux.NewRouter(), Store: store}
+	started := make(chan struct{})
+	app.Router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serve(ctx, l, cfg)
+	}()
+
+	responses := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			responses <- 0
+			return
+		}
+		response.Body.Close()
+		responses <- response.StatusCode
+	}()
+
+	// shutdown while the request is in flight
+	<-started
+	cancel()
+
+	if code := <-responses; code != http.StatusOK {
+		t.Errorf("Expected the in-flight request to finish with %d. Got %d", http.StatusOK, code)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown. Got '%v'", err)
+	}
+	if !store.closed {
+		t.Error("Expected the store to be closed")
+	}
+
+	// no longer accepting connections
+	if _, err := http.Get("http://" + l.Addr().String() + "/slow"); err == nil {
+		t.Error("Expected the server to be closed")
+	}
+}
+
+// Store that fails to ping a number of times
+type flakyStore struct {
+	*memoryStore
+	failures int
+}
+
+func (s *flakyStore) Ping(ctx context.Context) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// Tests startup retries until the database answers, and gives up after the timeout
+func TestWaitForStore(t *testing.T) {
+	if err := waitForStore(&flakyStore{memoryStore: newMemoryStore(), failures: 2}, 5*time.Second); err != nil {
+		t.Errorf("Expected the store to become ready. Got '%v'", err)
+	}
+
+	if err := waitForStore(&flakyStore{memoryStore: newMemoryStore(), failures: 1000}, 500*time.Millisecond); err == nil {
+		t.Error("Expected an error when the store never becomes ready")
+	}
+}
+
+// Tests the default configuration waits for a database that isn't up yet
+func TestWaitForStoreDefaultTimeout(t *testing.T) {
+	if err := waitForStore(&flakyStore{memoryStore: newMemoryStore(), failures: 1}, defaultConfig().ConnectTimeout.Duration); err != nil {
+		t.Errorf("Expected the default config to retry a failed ping. Got '%v'", err)
+	}
+}
@@ -0,0 +1,328 @@
+// config.go
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// App configuration
+// Precedence, lowest to highest: defaults, config file (-config or APP_CONFIG), environment variables, flags
+type Config struct {
+	Backend           string   `json:"backend" yaml:"backend"`                     // mysql, postgres, sqlite or memory
+	DSN               string   `json:"dsn" yaml:"dsn"`                             // database DSN or sqlite file path
+	MaxOpenConns      int      `json:"max_open_conns" yaml:"max_open_conns"`       // 0 means unlimited
+	MaxIdleConns      int      `json:"max_idle_conns" yaml:"max_idle_conns"`       // idle connections kept in the pool
+	ConnMaxLifetime   Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"` // 0 means connections are reused forever
+	ConnectTimeout    Duration `json:"connect_timeout" yaml:"connect_timeout"`     // how long startup waits for the database
+	ListenAddr        string   `json:"listen_addr" yaml:"listen_addr"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"` // time given to in-flight requests on SIGINT/SIGTERM
+
+	PrintConfig bool     `json:"-" yaml:"-"` // print the configuration (secrets redacted) and exit
+	Args        []string `json:"-" yaml:"-"` // command line arguments left after the flags, e.g. "migrate up"
+}
+
+// time.Duration written as "5s", "1m30s", ... in config files
+type Duration struct {
+	time.Duration
+}
+
+// Default configuration, matching docker-compose.yaml
+func defaultConfig() Config {
+	return Config{
+		Backend:           "mysql",
+		MaxOpenConns:      25,
+		MaxIdleConns:      25,
+		ConnectTimeout:    Duration{30 * time.Second},
+		ListenAddr:        ":3000",
+		ReadHeaderTimeout: Duration{5 * time.Second},
+		ReadTimeout:       Duration{10 * time.Second},
+		WriteTimeout:      Duration{10 * time.Second},
+		IdleTimeout:       Duration{60 * time.Second},
+		ShutdownTimeout:   Duration{15 * time.Second},
+	}
+}
+
+// Default DSN for backend
+func defaultDataSource(backend string) string {
+	switch backend {
+	case "sqlite":
+		return "guestlist.db"
+	case "postgres":
+		return postgresDataSource("user", "password", "postgres", "5432", "maindatabase")
+	case "mysql":
+		return mysqlDataSource("user", "password", "mysql", "3306", "maindatabase")
+	}
+
+	return ""
+}
+
+// Builds the configuration from command line arguments (args), environment variables (getenv) and the config file
+func loadConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("APP_CONFIG"), "YAML or JSON config file (env APP_CONFIG)")
+
+	// flag values are only applied if set, so their defaults are just for -help
+	flags := Config{}
+	fs.StringVar(&flags.Backend, "backend", cfg.Backend, "storage backend: mysql, postgres, sqlite or memory (env APP_BACKEND)")
+	fs.StringVar(&flags.DSN, "dsn", "", "database DSN or sqlite file path (env APP_DSN)")
+	fs.IntVar(&flags.MaxOpenConns, "max-open-conns", cfg.MaxOpenConns, "maximum open database connections, 0 for unlimited (env APP_MAX_OPEN_CONNS)")
+	fs.IntVar(&flags.MaxIdleConns, "max-idle-conns", cfg.MaxIdleConns, "maximum idle database connections (env APP_MAX_IDLE_CONNS)")
+	fs.DurationVar(&flags.ConnMaxLifetime.Duration, "conn-max-lifetime", cfg.ConnMaxLifetime.Duration, "maximum lifetime of a database connection, 0 for unlimited (env APP_CONN_MAX_LIFETIME)")
+	fs.DurationVar(&flags.ConnectTimeout.Duration, "connect-timeout", cfg.ConnectTimeout.Duration, "how long to wait for the database on startup (env APP_CONNECT_TIMEOUT)")
+	fs.StringVar(&flags.ListenAddr, "listen", cfg.ListenAddr, "HTTP listen address (env APP_LISTEN_ADDR)")
+	fs.DurationVar(&flags.ReadHeaderTimeout.Duration, "read-header-timeout", cfg.ReadHeaderTimeout.Duration, "HTTP request headers read timeout (env APP_READ_HEADER_TIMEOUT)")
+	fs.DurationVar(&flags.ReadTimeout.Duration, "read-timeout", cfg.ReadTimeout.Duration, "HTTP read timeout (env APP_READ_TIMEOUT)")
+	fs.DurationVar(&flags.WriteTimeout.Duration, "write-timeout", cfg.WriteTimeout.Duration, "HTTP write timeout (env APP_WRITE_TIMEOUT)")
+	fs.DurationVar(&flags.IdleTimeout.Duration, "idle-timeout", cfg.IdleTimeout.Duration, "HTTP keep-alive idle timeout (env APP_IDLE_TIMEOUT)")
+	fs.DurationVar(&flags.ShutdownTimeout.Duration, "shutdown-timeout", cfg.ShutdownTimeout.Duration, "time given to in-flight requests on shutdown (env APP_SHUTDOWN_TIMEOUT)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the configuration with secrets redacted and exit")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	cfg.Args = fs.Args()
+
+	// config file
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return cfg, err
+		}
+	}
+
+	// environment variables
+	if err := cfg.loadEnv(getenv); err != nil {
+		return cfg, err
+	}
+
+	// flags that were set on the command line
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "backend":
+			cfg.Backend = flags.Backend
+		case "dsn":
+			cfg.DSN = flags.DSN
+		case "max-open-conns":
+			cfg.MaxOpenConns = flags.MaxOpenConns
+		case "max-idle-conns":
+			cfg.MaxIdleConns = flags.MaxIdleConns
+		case "conn-max-lifetime":
+			cfg.ConnMaxLifetime = flags.ConnMaxLifetime
+		case "connect-timeout":
+			cfg.ConnectTimeout = flags.ConnectTimeout
+		case "listen":
+			cfg.ListenAddr = flags.ListenAddr
+		case "read-header-timeout":
+			cfg.ReadHeaderTimeout = flags.ReadHeaderTimeout
+		case "read-timeout":
+			cfg.ReadTimeout = flags.ReadTimeout
+		case "write-timeout":
+			cfg.WriteTimeout = flags.WriteTimeout
+		case "idle-timeout":
+			cfg.IdleTimeout = flags.IdleTimeout
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = flags.ShutdownTimeout
+		}
+	})
+
+	if cfg.DSN == "" {
+		cfg.DSN = defaultDataSource(cfg.Backend)
+	}
+
+	return cfg, cfg.validate()
+}
+
+// Reads the YAML (.yaml, .yml) or JSON (.json) config file at path over cfg
+func (cfg *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	case ".json":
+		err = json.Unmarshal(content, cfg)
+	default:
+		return fmt.Errorf("config file %s: unknown format, expected .yaml, .yml or .json", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Reads the APP_* environment variables over cfg
+func (cfg *Config) loadEnv(getenv func(string) string) error {
+	strs := map[string]*string{
+		"APP_BACKEND":     &cfg.Backend,
+		"APP_DSN":         &cfg.DSN,
+		"APP_LISTEN_ADDR": &cfg.ListenAddr,
+	}
+	ints := map[string]*int{
+		"APP_MAX_OPEN_CONNS": &cfg.MaxOpenConns,
+		"APP_MAX_IDLE_CONNS": &cfg.MaxIdleConns,
+	}
+	durations := map[string]*Duration{
+		"APP_CONN_MAX_LIFETIME":   &cfg.ConnMaxLifetime,
+		"APP_CONNECT_TIMEOUT":     &cfg.ConnectTimeout,
+		"APP_READ_HEADER_TIMEOUT": &cfg.ReadHeaderTimeout,
+		"APP_READ_TIMEOUT":        &cfg.ReadTimeout,
+		"APP_WRITE_TIMEOUT":       &cfg.WriteTimeout,
+		"APP_IDLE_TIMEOUT":        &cfg.IdleTimeout,
+		"APP_SHUTDOWN_TIMEOUT":    &cfg.ShutdownTimeout,
+	}
+
+	for name, s := range strs {
+		if v := getenv(name); v != "" {
+			*s = v
+		}
+	}
+
+	for name, i := range ints {
+		if v := getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			*i = n
+		}
+	}
+
+	for name, d := range durations {
+		if v := getenv(name); v != "" {
+			if err := d.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks the configuration is usable
+func (cfg Config) validate() error {
+	switch cfg.Backend {
+	case "mysql", "postgres", "sqlite", "memory":
+	default:
+		return fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+
+	if cfg.Backend != "memory" && cfg.DSN == "" {
+		return fmt.Errorf("the %s backend needs a DSN", cfg.Backend)
+	}
+	if cfg.ListenAddr == "" {
+		return errors.New("listen address can't be empty")
+	}
+	if cfg.MaxOpenConns < 0 || cfg.MaxIdleConns < 0 {
+		return errors.New("database pool sizes can't be negative")
+	}
+	if cfg.MaxOpenConns > 0 && cfg.MaxIdleConns > cfg.MaxOpenConns {
+		return errors.New("max idle connections can't exceed max open connections")
+	}
+
+	durations := map[string]Duration{
+		"conn_max_lifetime":   cfg.ConnMaxLifetime,
+		"connect_timeout":     cfg.ConnectTimeout,
+		"read_header_timeout": cfg.ReadHeaderTimeout,
+		"read_timeout":        cfg.ReadTimeout,
+		"write_timeout":       cfg.WriteTimeout,
+		"idle_timeout":        cfg.IdleTimeout,
+		"shutdown_timeout":    cfg.ShutdownTimeout,
+	}
+
+	for name, d := range durations {
+		if d.Duration < 0 {
+			return fmt.Errorf("%s can't be negative", name)
+		}
+	}
+
+	return nil
+}
+
+// Writes cfg as JSON to w, with the DSN password redacted
+func printConfig(w io.Writer, cfg Config) error {
+	cfg.DSN = redactDataSource(cfg.DSN)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(cfg)
+}
+
+// Key=value DSNs (lib/pq), "host=db user=u password=secret dbname=x"
+var (
+	keyValueDataSource = regexp.MustCompile(`^\s*\w+\s*=`)
+	keyValuePassword   = regexp.MustCompile(`(^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+)
+
+// Replaces the password of a URL (postgres), key=value (postgres) or mysql DSN with "xxxxx"
+func redactDataSource(dataSource string) string {
+	if strings.Contains(dataSource, "://") {
+		if u, err := url.Parse(dataSource); err == nil {
+			return u.Redacted()
+		}
+		return "xxxxx"
+	}
+
+	if keyValueDataSource.MatchString(dataSource) {
+		return keyValuePassword.ReplaceAllString(dataSource, "${1}password=xxxxx")
+	}
+
+	if c, err := mysql.ParseDSN(dataSource); err == nil && c.Passwd != "" {
+		c.Passwd = "xxxxx"
+		return c.FormatDSN()
+	}
+
+	return dataSource
+}
+
+// Parses "5s", "1m30s", ...
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+
+	return nil
+}
+
+// Parses "5s", "1m30s", ... in JSON strings
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return d.UnmarshalText([]byte(s))
+}
+
+// Writes the duration as "5s", "1m30s", ...
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
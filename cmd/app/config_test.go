@@ -0,0 +1,128 @@
+// config_test.go
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builds a getenv func from a map
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// Tests defaults, config file, environment and flags precedence
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(file, []byte("backend: sqlite\ndsn: file.db\nlisten_addr: \":4000\"\nread_timeout: 3s\nmax_open_conns: 5\nmax_idle_conns: 5\n"), 0600)
+
+	cfg, err := loadConfig(nil, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend != "mysql" || cfg.ListenAddr != ":3000" || cfg.DSN != mysqlDataSource("user", "password", "mysql", "3306", "maindatabase") {
+		t.Errorf("Expected the default configuration. Got '%+v'", cfg)
+	}
+
+	// file over defaults
+	cfg, err = loadConfig([]string{"-config", file}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend != "sqlite" || cfg.DSN != "file.db" || cfg.ListenAddr != ":4000" || cfg.ReadTimeout.Duration != 3*time.Second || cfg.MaxOpenConns != 5 {
+		t.Errorf("Expected the config file values. Got '%+v'", cfg)
+	}
+
+	// environment over file, flags over environment
+	cfg, err = loadConfig([]string{"-listen", ":6000", "migrate", "up"}, env(map[string]string{
+		"APP_CONFIG":       file,
+		"APP_LISTEN_ADDR":  ":5000",
+		"APP_READ_TIMEOUT": "1m",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend != "sqlite" || cfg.ListenAddr != ":6000" || cfg.ReadTimeout.Duration != time.Minute {
+		t.Errorf("Expected environment and flag values. Got '%+v'", cfg)
+	}
+	if strings.Join(cfg.Args, " ") != "migrate up" {
+		t.Errorf("Expected args 'migrate up'. Got '%v'", cfg.Args)
+	}
+
+	// JSON config file
+	file = filepath.Join(dir, "config.json")
+	ioutil.WriteFile(file, []byte(`{"backend": "memory", "write_timeout": "2s"}`), 0600)
+
+	cfg, err = loadConfig([]string{"-config", file}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend != "memory" || cfg.WriteTimeout.Duration != 2*time.Second {
+		t.Errorf("Expected the JSON config file values. Got '%+v'", cfg)
+	}
+}
+
+// Tests invalid configurations are rejected
+func TestLoadConfigValidation(t *testing.T) {
+	invalid := []map[string]string{
+		{"APP_BACKEND": "oracle"},
+		{"APP_MAX_OPEN_CONNS": "ten"},
+		{"APP_MAX_OPEN_CONNS": "5", "APP_MAX_IDLE_CONNS": "10"},
+		{"APP_READ_TIMEOUT": "-1s"},
+		{"APP_CONFIG": "config.toml"},
+	}
+
+	for _, vars := range invalid {
+		if _, err := loadConfig(nil, env(vars)); err == nil {
+			t.Errorf("Expected an error for %v", vars)
+		}
+	}
+}
+
+// Tests -print-config doesn't leak passwords
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	for _, dsn := range []string{
+		mysqlDataSource("user", "s3cret", "mysql", "3306", "maindatabase"),
+		postgresDataSource("user", "s3cret", "postgres", "5432", "maindatabase"),
+		"host=postgres user=user password=s3cret dbname=maindatabase",
+		"host=postgres password = 'my s3cret \\' pass' dbname=maindatabase",
+	} {
+		var out bytes.Buffer
+		printConfig(&out, Config{Backend: "mysql", DSN: dsn})
+
+		if strings.Contains(out.String(), "s3cret") {
+			t.Errorf("Expected the password to be redacted. Got '%s'", out.String())
+		}
+		if !strings.Contains(out.String(), "xxxxx") {
+			t.Errorf("Expected a redacted DSN. Got '%s'", out.String())
+		}
+	}
+}
+
+// Tests only the password of key=value DSNs is masked, quoted or not
+func TestRedactKeyValueDataSource(t *testing.T) {
+	tests := map[string]string{
+		"host=db user=u password=secret dbname=x":   "host=db user=u password=xxxxx dbname=x",
+		"password='it\\'s secret' host=db":          "password=xxxxx host=db",
+		"host=db  password = 'a b' sslmode=disable": "host=db  password=xxxxx sslmode=disable",
+		"host=db user=u dbname=x":                   "host=db user=u dbname=x",
+	}
+
+	for dsn, expected := range tests {
+		if redacted := redactDataSource(dsn); redacted != expected {
+			t.Errorf("%s: expected '%s'. Got '%s'", dsn, expected, redacted)
+		}
+	}
+}
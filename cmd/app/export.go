@@ -0,0 +1,149 @@
+// export.go
+
+package main
+
+import (
+	"html/template"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Formats of the guest list and arrivals exports
+const (
+	formatJSON  = "json"  // the GuestList envelope
+	formatCSV   = "csv"   // a header row and a row per guest
+	formatJSONL = "jsonl" // a JSON object per line and guest
+	formatHTML  = "html"  // printable seating sheet, a page per table
+)
+
+// Media types of each export format, the first one is sent back
+var exportMediaTypes = map[string][]string{
+	formatJSON:  {"application/json"},
+	formatCSV:   {"text/csv"},
+	formatJSONL: {"application/x-ndjson", "application/jsonl", "application/x-jsonlines"},
+	formatHTML:  {"text/html"},
+}
+
+// Guest as written on the CSV and JSONL exports, every field always present
+type exportGuest struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	Table              int    `json:"table"`
+	AccompanyingGuests int    `json:"accompanying_guests"`
+	Arrived            bool   `json:"arrived"`
+	Present            int    `json:"present"`
+	TimeArrived        string `json:"time_arrived"`
+	TimeLeft           string `json:"time_left"`
+}
+
+// Header of the CSV export, in exportGuest order
+var exportColumns = []string{"id", "name", "table", "accompanying_guests", "arrived", "present", "time_arrived", "time_left"}
+
+// Export format from ?format=, else the first known type on the Accept header, JSON by default
+// False for an unknown ?format=
+func exportFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		_, ok := exportMediaTypes[format]
+		return format, ok
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		for format, types := range exportMediaTypes {
+			for _, t := range types {
+				if t == mediaType {
+					return format, true
+				}
+			}
+		}
+	}
+
+	return formatJSON, true
+}
+
+// Writes the guests (only the arrived ones if arrived = true) as format, which isn't formatJSON
+// name is the file name offered for download, title heads the seating sheet
+// CSV and JSON Lines are streamed as rows are read, the seating sheet needs the whole venue first
+func (a *App) exportGuests(w http.ResponseWriter, format string, arrived bool, name string, title string) {
+
+	contentType := exportMediaTypes[format][0] + "; charset=utf-8"
+
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+		a.streamGuests(w, arrived, contentType, &csvEncoder{})
+		return
+	case formatJSONL:
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+		a.streamGuests(w, arrived, contentType, &jsonlEncoder{})
+		return
+	}
+
+	tables, guests, err := a.Store.GetSeating(0)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	if arrived {
+		here := []Guest{}
+		for _, g := range guests {
+			if g.Arrived == 1 {
+				here = append(here, g)
+			}
+		}
+		guests = here
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	// the status is sent, a failing write can only cut the sheet short
+	seatingSheet.Execute(w, map[string]interface{}{"Title": title, "Occupancy": buildOccupancy(tables, guests)})
+}
+
+// Exported fields of guest (g)
+func exportRow(g Guest) exportGuest {
+	return exportGuest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests, Arrived: g.Arrived == 1, Present: g.Present, TimeArrived: g.TimeArrived, TimeLeft: g.TimeLeft}
+}
+
+// Printable seating sheet, a page per table with its guests
+var seatingSheet = template.Must(template.New("seating").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+	body { font-family: sans-serif; }
+	section { page-break-after: always; }
+	table { border-collapse: collapse; width: 100%; }
+	th, td { border: 1px solid #999; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+{{- range .Occupancy.Tables}}
+<section>
+	<h1>{{$.Title}} - Table {{.Number}}</h1>
+	<p>{{.Capacity}} seats, {{.Reserved}} reserved, {{.Arrived}} present</p>
+	{{- if .Guests}}
+	<table>
+		<thead><tr><th>Guest</th><th>Accompanying guests</th><th>Present</th><th>Arrived at</th><th>Left at</th></tr></thead>
+		<tbody>
+		{{- range .Guests}}
+			<tr><td>{{.Name}}</td><td>{{.AccompanyingGuests}}</td><td>{{.Present}}</td><td>{{.TimeArrived}}</td><td>{{.TimeLeft}}</td></tr>
+		{{- end}}
+		</tbody>
+	</table>
+	{{- else}}
+	<p>No guests</p>
+	{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
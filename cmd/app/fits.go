@@ -0,0 +1,221 @@
+// fits.go
+
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A table that can take a party
+type FitTable struct {
+	Number     int           `json:"table_number"`
+	Seats      int           `json:"seats"`
+	SeatsEmpty int           `json:"seats_empty"`
+	SeatsLeft  int           `json:"seats_left"`      // free seats once the party is seated
+	Preferred  bool          `json:"preferred"`       // one of the tables asked for
+	Moves      []PlannedMove `json:"moves,omitempty"` // guests to reseat first, when it takes a reshuffle
+}
+
+// Struct used for /venue/fits endpoint response
+type Fits struct {
+	PartySize      int        `json:"party_size"`
+	Tables         []FitTable `json:"tables"`          // tables with room now
+	AfterReshuffle []FitTable `json:"after_reshuffle"` // tables with room once guests who haven't arrived move elsewhere
+}
+
+// Tables where a party of size can sit, now or after moving guests who haven't arrived yet
+// Preferred tables come first, then the ones the party fills the most
+func buildFits(tables []Table, guests []Guest, size int, preferred []int) Fits {
+	fits := Fits{PartySize: size, Tables: []FitTable{}, AfterReshuffle: []FitTable{}}
+
+	isPreferred := map[int]bool{}
+	for _, table := range preferred {
+		isPreferred[table] = true
+	}
+
+	// guests who haven't arrived, by table, the only ones a reshuffle may move
+	movable := map[int][]Guest{}
+	for _, g := range guests {
+		if g.Arrived == 0 && g.TimeLeft == "" {
+			movable[g.Table] = append(movable[g.Table], g)
+		}
+	}
+
+	for _, t := range tables {
+		fit := FitTable{Number: t.Number, Seats: t.Seats, SeatsEmpty: t.SeatsEmpty, SeatsLeft: t.SeatsEmpty - size, Preferred: isPreferred[t.Number]}
+
+		if fit.SeatsLeft >= 0 {
+			fits.Tables = append(fits.Tables, fit)
+			continue
+		}
+
+		moves, freed := makeRoom(tables, t.Number, movable[t.Number], -fit.SeatsLeft)
+		if moves == nil {
+			continue
+		}
+
+		fit.Moves = moves
+		fit.SeatsLeft += freed
+		fits.AfterReshuffle = append(fits.AfterReshuffle, fit)
+	}
+
+	sortFits(fits.Tables)
+	sortFits(fits.AfterReshuffle)
+
+	return fits
+}
+
+// Moves of guests off table (largest parties first) onto the free seats of other tables until need seats are freed
+// Returns the moves and the seats they free, nil moves if the guests who haven't arrived can't free enough.
+// Each table is checked on its own, so this stays linear in the guests of table rather than replanning the venue
+func makeRoom(tables []Table, table int, movable []Guest, need int) ([]PlannedMove, int) {
+	heads, room := 0, 0
+	for _, g := range movable {
+		heads += partySize(g)
+	}
+
+	free := []Table{}
+	for _, t := range tables {
+		if t.Number != table && t.SeatsEmpty > 0 {
+			free = append(free, t)
+			room += t.SeatsEmpty
+		}
+	}
+
+	if heads < need || room < need {
+		return nil, 0
+	}
+
+	parties := append([]Guest{}, movable...)
+	sort.SliceStable(parties, func(i, j int) bool {
+		if partySize(parties[i]) != partySize(parties[j]) {
+			return partySize(parties[i]) > partySize(parties[j])
+		}
+		return parties[i].ID < parties[j].ID
+	})
+
+	moves, freed := []PlannedMove{}, 0
+	for _, g := range parties {
+		if freed >= need {
+			break
+		}
+
+		to := pickBestFit(free, partySize(g))
+		if to == 0 {
+			continue
+		}
+
+		for i := range free {
+			if free[i].Number == to {
+				free[i].SeatsEmpty -= partySize(g)
+			}
+		}
+
+		moves = append(moves, PlannedMove{ID: g.ID, Name: g.Name, From: table, To: to})
+		freed += partySize(g)
+	}
+
+	if freed < need {
+		return nil, 0
+	}
+
+	return moves, freed
+}
+
+// Orders fits preferred first, then by fewest seats left, then by table number
+func sortFits(fits []FitTable) {
+	sort.SliceStable(fits, func(i, j int) bool {
+		if fits[i].Preferred != fits[j].Preferred {
+			return fits[i].Preferred
+		}
+		if fits[i].SeatsLeft != fits[j].SeatsLeft {
+			return fits[i].SeatsLeft < fits[j].SeatsLeft
+		}
+		return fits[i].Number < fits[j].Number
+	})
+}
+
+/*
+### What fits
+
+Lists the tables that can take a party of party_size right now, with the seats left once they sit down,
+and the tables that could take them if their guests who haven't arrived yet moved to free seats elsewhere (with the moves that make room).
+Tables in ?preferred= (comma separated) are listed first, then the ones the party fills the most.
+Unknown preferred tables are an error (http.StatusNotFound).
+
+GET /venue/fits?party_size=int&preferred=int,int
+response:
+{
+	"party_size": int,
+	"tables": [
+		{
+			"table_number": int,
+			"seats": int,
+			"seats_empty": int,
+			"seats_left": int,
+			"preferred": bool
+		}, ...
+	],
+	"after_reshuffle": [
+		{
+			"table_number": int,
+			"seats": int,
+			"seats_empty": int,
+			"seats_left": int,
+			"preferred": bool,
+			"moves": [
+				{
+					"id": int,
+					"name": "string",
+					"from": int,
+					"to": int
+				}, ...
+			]
+		}, ...
+	]
+}
+*/
+func (a *App) handlerFits(w http.ResponseWriter, r *http.Request) {
+
+	size, err := strconv.Atoi(r.URL.Query().Get("party_size"))
+	if err != nil || size <= 0 {
+		respondWithProblem(w, invalidParam("party_size", "party_size must be a positive number"))
+		return
+	}
+
+	preferred := []int{}
+	if param := r.URL.Query().Get("preferred"); param != "" {
+		for _, field := range strings.Split(param, ",") {
+			table, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || table <= 0 {
+				respondWithProblem(w, invalidParam("preferred", "preferred must be table numbers separated by commas"))
+				return
+			}
+			preferred = append(preferred, table)
+		}
+	}
+
+	tables, guests, err := a.Store.GetSeating(0)
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	known := map[int]bool{}
+	for _, t := range tables {
+		known[t.Number] = true
+	}
+
+	for _, table := range preferred {
+		if !known[table] {
+			respondWithProblem(w, ErrTableNotFound)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, buildFits(tables, guests, size, preferred))
+}
@@ -0,0 +1,37 @@
+// fits_test.go
+
+package main
+
+import "testing"
+
+// Tests making room on a full table by moving several parties, and giving up when a party fits nowhere
+func TestBuildFitsMakeRoom(t *testing.T) {
+	tables := []Table{
+		{Number: 1, Seats: 8, SeatsEmpty: 0},
+		{Number: 2, Seats: 4, SeatsEmpty: 3},
+		{Number: 3, Seats: 4, SeatsEmpty: 3},
+	}
+	guests := []Guest{
+		{ID: 1, Name: "A", Table: 1, AccompanyingGuests: 2},
+		{ID: 2, Name: "B", Table: 1, AccompanyingGuests: 2},
+		{ID: 3, Name: "C", Table: 1, AccompanyingGuests: 1, Arrived: 1},
+		{ID: 4, Name: "D", Table: 2, AccompanyingGuests: 0},
+		{ID: 5, Name: "E", Table: 3, AccompanyingGuests: 0},
+	}
+
+	// A and B move, C has arrived and keeps their seats
+	f := buildFits(tables, guests, 6, nil)
+	if len(f.AfterReshuffle) != 1 || f.AfterReshuffle[0].Number != 1 || f.AfterReshuffle[0].SeatsLeft != 0 || len(f.AfterReshuffle[0].Moves) != 2 {
+		t.Fatalf("Expected table 1 after moving A and B. Got %+v", f.AfterReshuffle)
+	}
+	for _, m := range f.AfterReshuffle[0].Moves {
+		if m.From != 1 || m.To == 1 || m.ID == 3 {
+			t.Errorf("Expected A and B to leave table 1. Got %+v", m)
+		}
+	}
+
+	// moving A and B frees only 6 seats on table 1, C stays
+	if f = buildFits(tables, guests, 7, nil); len(f.AfterReshuffle) != 0 {
+		t.Errorf("Expected no table for 7. Got %+v", f.AfterReshuffle)
+	}
+}
@@ -0,0 +1,237 @@
+// guests_v2.go
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Id of the /v2/guests/{id} route
+func guestID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+
+	return id, err == nil && id > 0
+}
+
+/*
+### Add a guest by id
+
+Like POST /guest_list/name, but the name may already be on the guest list.
+Throws http.StatusConflict if there is insufficient space at the table.
+Without a table, one is picked following ?strategy= as on POST /guest_list/name.
+?waitlist=true&priority=int queue guests turned away as on POST /guest_list/name.
+
+POST /v2/guests?strategy=best_fit|first_fit|balanced&waitlist=true&priority=int
+body:
+{
+	"name": "string",
+	"table": int,
+	"accompanying_guests": int
+}
+response:
+{
+	"id": int,
+	"name": "string",
+	"table": int,
+	"accompanying_guests": int
+}
+*/
+func (a *App) handlerInviteGuest(w http.ResponseWriter, r *http.Request) {
+
+	var g Guest
+
+	// Decoding request body into Guest struct
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&g); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	if err := validGuest(g); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	queue, priority, ok := requestWaitlist(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("priority", "priority must be a number"))
+		return
+	}
+
+	var err error
+
+	// Adding guest to guest list, on the table picked by the strategy if none was given
+	if g.Table == 0 {
+		pick, ok := requestStrategy(r)
+		if !ok {
+			respondWithProblem(w, invalidParam("strategy", "strategy must be best_fit, first_fit or balanced"))
+			return
+		}
+
+		err = a.Store.AssignGuest(&g, pick, false)
+	} else {
+		err = a.Store.InviteGuest(&g)
+	}
+
+	if errors.Is(err, ErrTableFull) && queue {
+		a.queueGuest(w, g, priority, false)
+		return
+	}
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Guest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests})
+}
+
+/*
+### Search guests by name
+
+Every guest called name, each with their id.
+
+GET /v2/guests?name=string
+response:
+{
+	"guests": [
+		{
+			"id": int,
+			"name": "string",
+			"table": int,
+			"accompanying_guests": int,
+			"arrived": int,
+			...
+		}, ...
+	]
+}
+*/
+func (a *App) handlerFindGuests(w http.ResponseWriter, r *http.Request) {
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		respondWithProblem(w, invalidParam("name", "name is required"))
+		return
+	}
+
+	guests, err := a.Store.FindGuests(name)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, GuestList{Guests: guests})
+}
+
+/*
+### Get a guest by id
+
+GET /v2/guests/id
+response:
+{
+	"id": int,
+	"name": "string",
+	"table": int,
+	"accompanying_guests": int,
+	"time_arrived": "string",
+	"arrived": int,
+	"time_left": "string",
+	"present": int
+}
+*/
+func (a *App) handlerGetGuestByID(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := guestID(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("id", "invalid guest id"))
+		return
+	}
+
+	g, err := a.Store.GetGuestByID(id)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, g)
+}
+
+/*
+### Rename a guest or move them to another table
+
+Both fields are optional. The new table must have free seats for the guest's party (http.StatusConflict otherwise).
+
+PATCH /v2/guests/id
+body:
+{
+	"name": "string",
+	"table": int
+}
+response: the guest, as on GET /v2/guests/id
+*/
+func (a *App) handlerEditGuest(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := guestID(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("id", "invalid guest id"))
+		return
+	}
+
+	var e GuestEdit
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&e); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	if e.Name != nil {
+		if err := validName(*e.Name); err != nil {
+			respondWithProblem(w, err)
+			return
+		}
+	}
+	if e.Table != nil && *e.Table <= 0 {
+		respondWithProblem(w, invalidParam("table", "table must be a positive number"))
+		return
+	}
+
+	g, err := a.Store.EditGuest(id, e)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.promoteWaitlist()
+
+	respondWithJSON(w, http.StatusOK, g)
+}
+
+/*
+### Remove a guest by id
+
+DELETE /v2/guests/id
+*/
+func (a *App) handlerDeleteGuestByID(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := guestID(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("id", "invalid guest id"))
+		return
+	}
+
+	if err := a.Store.DeleteGuestByID(id); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.promoteWaitlist()
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
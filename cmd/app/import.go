@@ -0,0 +1,368 @@
+// import.go
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Whether an import keeps the rows that fit when others fail
+type ImportMode string
+
+const (
+	importAllOrNothing ImportMode = "all_or_nothing" // nothing is kept if any row fails
+	importBestEffort   ImportMode = "best_effort"    // the rows that fit are kept
+	importValidate     ImportMode = "validate"       // nothing is kept, only the report
+)
+
+// A row of an imported guest list
+type importRow struct {
+	guest Guest
+	err   error // why the row can't be imported, before checking seats
+}
+
+// Outcome of an imported row
+type ImportResult struct {
+	Row    int    `json:"row"` // 1 for the first guest, not counting the CSV header
+	Name   string `json:"name"`
+	Status string `json:"status"` // imported, failed, or valid when nothing was kept
+	ID     int    `json:"id,omitempty"`
+	Table  int    `json:"table,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"` // code of the error, as on problem responses
+}
+
+// Struct used for /v2/guests/import endpoint response
+type ImportReport struct {
+	Mode     ImportMode     `json:"mode"`
+	Imported int            `json:"imported"`
+	Failed   int            `json:"failed"`
+	Rows     []ImportResult `json:"rows"`
+}
+
+// Reads guests from CSV with a header row: name is required, table and accompanying_guests are optional (0 if empty),
+// other columns are ignored
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV, a header row is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must have a name column")
+	}
+
+	// cell of column on record, empty if the record is short
+	cell := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	// number in column, 0 if empty
+	number := func(record []string, column string) (int, error) {
+		value := cell(record, column)
+		if value == "" {
+			return 0, nil
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, invalidParam(column, column+" must be a number")
+		}
+		return n, nil
+	}
+
+	rows := []importRow{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var row importRow
+		row.guest.Name = cell(record, "name")
+
+		if row.guest.Table, err = number(record, "table"); err != nil {
+			row.err = err
+		} else if row.guest.AccompanyingGuests, err = number(record, "accompanying_guests"); err != nil {
+			row.err = err
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Reads guests from a JSON array of {"name", "table", "accompanying_guests"} objects, other fields are ignored
+func parseImportJSON(r io.Reader) ([]importRow, error) {
+	var objects []json.RawMessage
+
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, errors.New("body must be a JSON array of guests")
+	}
+
+	rows := []importRow{}
+
+	for _, object := range objects {
+		var row importRow
+
+		if err := json.Unmarshal(object, &row.guest); err != nil {
+			row.err = &Error{Kind: ErrorInvalid, Code: "invalid_payload", Message: "guest must be an object with a name, table and accompanying_guests"}
+		}
+
+		// only the imported fields
+		row.guest = Guest{Name: row.guest.Name, Table: row.guest.Table, AccompanyingGuests: row.guest.AccompanyingGuests}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Imports rows into store, returning the per-row report
+// With importAllOrNothing, rows that fail validation keep the rest from being imported too, they are still checked against the seats
+func importGuests(store Store, rows []importRow, pick Strategy, unique bool, mode ImportMode) (ImportReport, error) {
+	report := ImportReport{Mode: mode, Rows: []ImportResult{}}
+
+	guests := []Guest{}
+	positions := []int{} // position on rows of each guest handed to the store
+
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = validGuest(rows[i].guest)
+		}
+		if rows[i].err == nil {
+			guests = append(guests, rows[i].guest)
+			positions = append(positions, i)
+		}
+	}
+
+	storeMode := mode
+	if mode == importAllOrNothing && len(guests) < len(rows) {
+		storeMode = importValidate
+	}
+
+	errs, err := store.ImportGuests(guests, pick, unique, storeMode)
+	if err != nil {
+		return report, err
+	}
+
+	for i, position := range positions {
+		rows[position].guest, rows[position].err = guests[i], errs[i]
+	}
+
+	failed := false
+	for _, row := range rows {
+		failed = failed || row.err != nil
+	}
+
+	kept := mode == importBestEffort || (mode == importAllOrNothing && !failed)
+
+	for i, row := range rows {
+		result := ImportResult{Row: i + 1, Name: row.guest.Name}
+
+		switch {
+		case row.err != nil:
+			result.Status, result.Error, result.Code = "failed", "internal error", "internal_error"
+
+			// details of unexpected errors aren't shown, as on problem responses
+			var e *Error
+			if errors.As(row.err, &e) {
+				result.Error, result.Code = e.Message, e.Code
+			}
+			report.Failed++
+		case kept:
+			result.Status, result.ID, result.Table = "imported", row.guest.ID, row.guest.Table
+			report.Imported++
+		default:
+			result.Status, result.Table = "valid", row.guest.Table
+		}
+
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report, nil
+}
+
+// Import mode from ?mode=, all_or_nothing if not given
+func requestImportMode(name string) (ImportMode, bool) {
+	switch mode := ImportMode(name); mode {
+	case "":
+		return importAllOrNothing, true
+	case importAllOrNothing, importBestEffort, importValidate:
+		return mode, true
+	}
+
+	return "", false
+}
+
+/*
+### Import guests
+
+Adds a whole guest list at once, from a JSON array (the default) or CSV with a header row (Content-Type: text/csv).
+Each guest has a name, and optionally a table (picked following ?strategy= as on POST /v2/guests when missing or 0)
+and accompanying_guests (0 when missing), other fields or columns are ignored.
+Rows are checked in order against the free seats left by the rows before them.
+?mode=all_or_nothing (default) imports nothing if any row fails (http.StatusConflict), best_effort imports the rows that fit
+and validate only reports. Names may repeat as on POST /v2/guests, ?unique=true refuses names already on the guest list.
+
+POST /v2/guests/import?mode=all_or_nothing|best_effort|validate&strategy=best_fit|first_fit|balanced&unique=true
+body:
+[
+	{
+		"name": "string",
+		"table": int,
+		"accompanying_guests": int
+	}, ...
+]
+or:
+name,table,accompanying_guests
+string,int,int
+...
+response:
+{
+	"mode": "string",
+	"imported": int,
+	"failed": int,
+	"rows": [
+		{
+			"row": int,
+			"name": "string",
+			"status": "imported|failed|valid",
+			"id": int,
+			"table": int,
+			"error": "string",
+			"code": "string"
+		}, ...
+	]
+}
+*/
+func (a *App) handlerImportGuests(w http.ResponseWriter, r *http.Request) {
+
+	mode, ok := requestImportMode(r.URL.Query().Get("mode"))
+	if !ok {
+		respondWithProblem(w, invalidParam("mode", "mode must be all_or_nothing, best_effort or validate"))
+		return
+	}
+
+	pick, ok := requestStrategy(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("strategy", "strategy must be best_fit, first_fit or balanced"))
+		return
+	}
+
+	parse := parseImportJSON
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType == "text/csv" {
+		parse = parseImportCSV
+	}
+
+	rows, err := parse(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+
+	report, err := importGuests(a.Store, rows, pick, r.URL.Query().Get("unique") == "true", mode)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	if mode == importAllOrNothing && report.Failed > 0 {
+		respondWithJSON(w, http.StatusConflict, report)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// Handles the "import" command: import [-mode=...] [-strategy=...] [-unique] file.csv|file.json
+// Prints the report and fails if any row did
+func importCommand(store Store, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	modeName := fs.String("mode", string(importAllOrNothing), "all_or_nothing, best_effort or validate")
+	strategy := fs.String("strategy", "best_fit", "table for guests without one: best_fit, first_fit or balanced")
+	unique := fs.Bool("unique", false, "refuse names already on the guest list")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [-mode=all_or_nothing|best_effort|validate] [-strategy=best_fit|first_fit|balanced] [-unique] file.csv|file.json")
+	}
+
+	mode, ok := requestImportMode(*modeName)
+	if !ok {
+		return fmt.Errorf("unknown import mode %q", *modeName)
+	}
+
+	pick, ok := strategies[*strategy]
+	if !ok {
+		return fmt.Errorf("unknown strategy %q", *strategy)
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parse := parseImportJSON
+	if strings.EqualFold(filepath.Ext(fs.Arg(0)), ".csv") {
+		parse = parseImportCSV
+	}
+
+	rows, err := parse(file)
+	if err != nil {
+		return err
+	}
+
+	report, err := importGuests(store, rows, pick, *unique, mode)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, len(report.Rows))
+	}
+
+	return nil
+}
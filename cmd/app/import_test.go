@@ -0,0 +1,54 @@
+// import_test.go
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests the "import" command reading a CSV file
+func TestImportCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "guests.csv")
+	if err := ioutil.WriteFile(path, []byte("name,table,accompanying_guests\nA,1,2\nB,1,9\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newMemoryStore()
+	store.AddTable(6)
+
+	var out bytes.Buffer
+
+	// B doesn't fit, so A isn't imported either
+	if err := importCommand(store, []string{path}, &out); err == nil || !strings.Contains(out.String(), `"failed": 1`) {
+		t.Errorf("Expected the import to fail on B. Got '%v'\n%s", err, out.String())
+	}
+	if gl, _ := store.GetGuestList(); len(gl.Guests) != 0 {
+		t.Errorf("Expected no guests. Got %+v", gl.Guests)
+	}
+
+	out.Reset()
+	if err := importCommand(store, []string{"-mode=best_effort", path}, &out); err == nil || !strings.Contains(out.String(), `"imported": 1`) {
+		t.Errorf("Expected A imported. Got '%v'\n%s", err, out.String())
+	}
+	if g, err := store.GetGuest("A"); err != nil || g.Table != 1 {
+		t.Errorf("Expected A on table 1. Got %+v (%v)", g, err)
+	}
+
+	if err := importCommand(store, []string{"-mode=maybe", path}, &out); err == nil {
+		t.Error("Expected an unknown mode to fail")
+	}
+	if err := importCommand(store, []string{}, &out); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+}
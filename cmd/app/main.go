@@ -1,20 +1,68 @@
 package main
 
+import (
+	"flag"
+	"log"
+	"os"
+)
+
 func main() {
 
 	a := App{}
 
-	//database info
-	username := "user"
-	password := "password"
-	database := "maindatabase"
-	host := "mysql"
-	port := "3306"
+	// configuration from flags, APP_* environment variables and config file
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.PrintConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// init DB
-	a.Init(username, password, host, port, database)
+	store, err := openStore(cfg)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// sql.Open doesn't connect, wait for the database to accept connections
+	if err := waitForStore(store, cfg.ConnectTimeout.Duration); err != nil {
+		log.Fatal(err)
+	}
+
+	// "app migrate up|down|version" manages the schema and exits
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := migrateCommand(store, cfg.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// apply pending migrations, refusing to run on a schema from a newer build
+	if err := migrateStore(store); err != nil {
+		log.Fatal(err)
+	}
+
+	// "app import file.csv|file.json" adds a guest list and exits
+	if len(cfg.Args) > 0 && cfg.Args[0] == "import" {
+		if err := importCommand(store, cfg.Args[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	a.Init(store)
 
-	// listen on port :3000
-	a.Run(":3000")
+	// listen on cfg.ListenAddr (:3000 by default)
+	a.Run(cfg)
 
 }
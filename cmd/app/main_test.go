@@ -1,43 +1,29 @@
 // main_test.go
 
-// running tests: <CGO_ENABLED=0> go test -v cmd/app/app.go cmd/app/model.go cmd/app/main.go cmd/app/main_test.go
+// running tests: <CGO_ENABLED=0> go test -v ./cmd/app
 package main
 
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
-// Used to create the "venue" table
-const VenueCreationQuery = `CREATE TABLE IF NOT EXISTS venue
-(
-	table_number INT NOT NULL auto_increment,
-	seats INT UNSIGNED NOT NULL DEFAULT 4,
-	
-	PRIMARY KEY (table_number)
-);`
-
-// Used to create the "guestlist" table
-const GuestListCreationQuery = `CREATE TABLE IF NOT EXISTS guestlist (
-	id INT NOT NULL auto_increment,
-	guest_name VARCHAR (64) CHARACTER SET utf8 UNIQUE,
-	table_number INT NOT NULL,
-	accompanying_guests INT UNSIGNED NOT NULL, 
-	time_arrived TIMESTAMP,
-	arrived BOOLEAN DEFAULT FALSE,
-	
-	PRIMARY KEY (id),
-	FOREIGN KEY (table_number) REFERENCES venue(table_number)
-  );`
-
 var a App
+var db *sqlStore // only set for the SQL backends
+
+// Backend the tests run against, set with APP_BACKEND ("memory" by default, "mysql", "postgres" or "sqlite")
+var backend string
 
 func TestMain(m *testing.M) {
 
@@ -48,11 +34,39 @@ func TestMain(m *testing.M) {
 	host := "mysql"
 	port := "3306"
 
+	backend = os.Getenv("APP_BACKEND")
+	if backend == "" {
+		backend = "memory"
+	}
+
+	cfg := defaultConfig()
+	cfg.Backend = backend
+
+	// APP_DSN points the tests at another database
+	cfg.DSN = os.Getenv("APP_DSN")
+	if cfg.DSN == "" {
+		switch backend {
+		case "sqlite":
+			cfg.DSN = filepath.Join(os.TempDir(), "guestlist_test.db")
+			os.Remove(cfg.DSN)
+		case "postgres":
+			cfg.DSN = postgresDataSource(username, password, "postgres", "5432", database)
+		default:
+			cfg.DSN = mysqlDataSource(username, password, host, port, database)
+		}
+	}
+
 	// init DB
-	a.Init(username, password, host, port, database)
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	//making sure tables exist
-	createTables()
+	a.Init(store)
+
+	if s, ok := store.(*sqlStore); ok {
+		db = s
+	}
 
 	code := m.Run()
 
@@ -61,51 +75,76 @@ func TestMain(m *testing.M) {
 
 }
 
-//Creates the necessary tables if they don't exist
-func createTables() {
-	if _, err := a.DB.Exec(VenueCreationQuery); err != nil {
+//Resets database's tables
+func resetDB() {
+	if db == nil {
+		a.Store = newMemoryStore()
+		return
+	}
+
+	// the migrations are the schema, reverting and reapplying them also resets auto increments
+	if err := db.migrateDown(0); err != nil {
 		log.Fatal(err)
 	}
-	if _, err := a.DB.Exec(GuestListCreationQuery); err != nil {
+	if err := db.migrateUp(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-//Resets database's tables
-func resetDB() {
-	a.DB.Exec("DELETE FROM guestlist")
-	a.DB.Exec("ALTER TABLE guestlist AUTO_INCREMENT = 1")
-	a.DB.Exec("DELETE FROM venue")
-	a.DB.Exec("ALTER TABLE venue AUTO_INCREMENT = 1")
+// App for the concurrency tests, on an empty database: the test database, or a SQLite one when the tests run
+// on the memory backend, whose single mutex would pass them without the SQL backends' row locks being exercised
+func concurrentApp(t *testing.T) *App {
+	if db != nil {
+		resetDB()
+		return &a
+	}
+
+	s, err := newSQLiteStore(filepath.Join(t.TempDir(), "concurrent.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.migrateUp(); err != nil {
+		t.Skipf("SQLite unavailable (%v), build with cgo or set APP_BACKEND", err)
+	}
 
+	app := &App{}
+	app.Init(s)
+
+	return app
 }
 
 func initializeDB() {
 	resetDB()
-	addTable(a.DB, 12)
-	addTable(a.DB, 12)
-	addTable(a.DB, 12)
+	a.Store.AddTable(12)
+	a.Store.AddTable(12)
+	a.Store.AddTable(12)
 }
 
 // Adds guests to DB, if arrived = true it alternates between "arrived" guests and regular additions to guestlist
 func addGuests(count int, arrived bool) {
 
-	if arrived { // sets arrived flag = true every other guest
-		for i := 1; i <= count; i++ {
-			//if it's an arrived guest, guestlist table arrived field = 1; mysql automatically sets time_arrived=NOW()
-			a.DB.Exec("INSERT INTO guestlist(guest_name, table_number, accompanying_guests, arrived) VALUES(?, ?, ?, ?)", "TestGuest"+strconv.Itoa(i), i%3+1, (i * 4 % 12), i%2)
-		}
-	} else { // sets arrived flag = false
-		for i := 1; i <= count; i++ {
-			a.DB.Exec("INSERT INTO guestlist(guest_name, table_number, accompanying_guests, arrived) VALUES(?, ?, ?, ?)", "TestGuest"+strconv.Itoa(i), i%3+1, (i * 4 % 12), 0)
+	for i := 1; i <= count; i++ {
+		g := Guest{Name: "TestGuest" + strconv.Itoa(i), Table: i%3 + 1, AccompanyingGuests: i * 4 % 12}
+		a.Store.AddGuest(&g)
+
+		// sets arrived flag = true every other guest, time_arrived is set on arrival
+		if arrived && i%2 == 1 {
+			a.Store.UpdateGuest(&g)
 		}
 	}
 }
 
 // Executes a given query
 func executeRequest(req *http.Request) *httptest.ResponseRecorder {
+	return executeRequestOn(&a, req)
+}
+
+// Runs request (req) on app
+func executeRequestOn(app *App, req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
-	a.Router.ServeHTTP(rr, req)
+	app.Router.ServeHTTP(rr, req)
 
 	return rr
 }
@@ -147,18 +186,20 @@ func TestHandlerAddTable(t *testing.T) {
 
 	checkResponseCode(t, http.StatusCreated, response.Code)
 
-	// Query table for contents
-	var table_number, seats int
-	err := a.DB.QueryRow("SELECT * FROM venue").Scan(&table_number, &seats)
+	// Query venue for contents
+	tables, err := a.Store.GetTables()
 
 	if err != nil {
 		t.Errorf("database issue %s", err)
 	}
-	if table_number != 1 {
-		t.Errorf("Expected table_number to be 1. Got '%d'", table_number)
+	if len(tables) != 1 {
+		t.Fatalf("Expected 1 table. Got '%d'", len(tables))
+	}
+	if tables[0].Number != 1 {
+		t.Errorf("Expected table_number to be 1. Got '%d'", tables[0].Number)
 	}
-	if seats != 4 {
-		t.Errorf("Expected seats to be 4. Got '%d'", seats)
+	if tables[0].Seats != 4 {
+		t.Errorf("Expected seats to be 4. Got '%d'", tables[0].Seats)
 	}
 }
 
@@ -295,7 +336,7 @@ func TestHandlerGetGuest(t *testing.T) {
 func TestHandlerGuestLeaves(t *testing.T) {
 	initializeDB()
 
-	addGuests(1, false) //adding 1 guest
+	addGuests(1, false) //adding 1 guest, 5 seats on table 2
 
 	req, _ := http.NewRequest("GET", "/guests/TestGuest1", nil)
 	response := executeRequest(req)
@@ -308,14 +349,97 @@ func TestHandlerGuestLeaves(t *testing.T) {
 		t.Errorf("Expected name to be 'TestGuest1'. Got '%v'", g.Name)
 	}
 
+	// hasn't arrived yet
+	req, _ = http.NewRequest("DELETE", "/guests/TestGuest1", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	req, _ = http.NewRequest("PUT", "/guests/TestGuest1", bytes.NewBuffer([]byte(`{"accompanying_guests":4}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("DELETE", "/guests/TestGuest1", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	// still on the guestlist, with the departure recorded and the seats released
+	req, _ = http.NewRequest("GET", "/guests/TestGuest1", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	g = Guest{}
+	json.Unmarshal(response.Body.Bytes(), &g)
+
+	if g.Arrived != 0 || g.TimeLeft == "" {
+		t.Errorf("Expected a departed guest. Got '%s'", response.Body.String())
+	}
+
+	if free, _ := a.Store.GetFreeSeats(2, false); free != 12 {
+		t.Errorf("Expected 12 free seats on table 2. Got '%d'", free)
+	}
+
+	req, _ = http.NewRequest("GET", "/guests", nil)
+	response = executeRequest(req)
+	if response.Body.String() != `{"guests":[]}` {
+		t.Errorf("Expected no arrived guests. Got '%s'", response.Body.String())
+	}
+
 	req, _ = http.NewRequest("DELETE", "/guests/TestGuest1", nil)
 	response = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	// the seats were given away in the meantime
+	a.Store.AddGuest(&Guest{Name: "Latecomer", Table: 2, AccompanyingGuests: 8})
+
+	req, _ = http.NewRequest("PUT", "/guests/TestGuest1", bytes.NewBuffer([]byte(`{"accompanying_guests":4}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	// re-admitted with a smaller entourage
+	req, _ = http.NewRequest("PUT", "/guests/TestGuest1", bytes.NewBuffer([]byte(`{"accompanying_guests":2}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/guests/TestGuest1", nil)
+	response = executeRequest(req)
+
+	g = Guest{}
+	json.Unmarshal(response.Body.Bytes(), &g)
+
+	if g.Arrived != 1 || g.TimeLeft != "" {
+		t.Errorf("Expected a re-admitted guest. Got '%s'", response.Body.String())
+	}
+
+	if free, _ := a.Store.GetFreeSeats(2, false); free != 0 {
+		t.Errorf("Expected 0 free seats on table 2. Got '%d'", free)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/guests/Nobody", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
 
+// Tests removing a guest from the guestlist DELETE /guest_list/name
+func TestHandlerUninviteGuest(t *testing.T) {
+	initializeDB()
+
+	addGuests(1, true)
+
+	req, _ := http.NewRequest("DELETE", "/guest_list/TestGuest1", nil)
+	response := executeRequest(req)
 	checkResponseCode(t, http.StatusOK, response.Code)
 
 	req, _ = http.NewRequest("GET", "/guests/TestGuest1", nil)
 	response = executeRequest(req)
 	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	if free, _ := a.Store.GetFreeSeats(2, false); free != 12 {
+		t.Errorf("Expected 12 free seats on table 2. Got '%d'", free)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/guest_list/TestGuest1", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
 }
 
 // testing hanbdlerArrivedGuests() GET /guests
@@ -402,3 +526,1180 @@ func TestHandlerSeatsEmpty(t *testing.T) {
 		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
 	}
 }
+
+// Fires concurrent POST /guest_list/name and PUT /guests/name requests for the
+// last seats of a table and checks the table is never over capacity
+func TestConcurrentSeatAllocation(t *testing.T) {
+	app := concurrentApp(t)
+	app.Store.AddTable(10)
+	app.Store.AddTable(10)
+
+	const requests = 200
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			jsonStr := []byte(`{"table": 1, "accompanying_guests": 1}`)
+			req, _ := http.NewRequest("POST", "/guest_list/Concurrent"+strconv.Itoa(i), bytes.NewBuffer(jsonStr))
+			req.Header.Set("Content-Type", "application/json")
+
+			if response := executeRequestOn(app, req); response.Code == http.StatusCreated {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 10 seats fit 5 parties of 2
+	if created != 5 {
+		t.Errorf("Expected 5 guests to be added. Got '%d'", created)
+	}
+	if free, err := app.Store.GetFreeSeats(1, false); err != nil || free != 0 {
+		t.Errorf("Expected 0 free seats on table 1. Got '%d' (%v)", free, err)
+	}
+
+	// parties on table 2 arriving with a bigger entourage compete for the same seats
+	for i := 1; i <= 5; i++ {
+		app.Store.AddGuest(&Guest{Name: "Arriving" + strconv.Itoa(i), Table: 2})
+	}
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			jsonStr := []byte(`{"accompanying_guests": ` + strconv.Itoa(i%3) + `}`)
+			req, _ := http.NewRequest("PUT", "/guests/Arriving"+strconv.Itoa(i%5+1), bytes.NewBuffer(jsonStr))
+			req.Header.Set("Content-Type", "application/json")
+
+			executeRequestOn(app, req)
+		}(i)
+	}
+	wg.Wait()
+
+	if free, err := app.Store.GetFreeSeats(2, false); err != nil || free < 0 {
+		t.Errorf("Expected table 2 not to be over capacity. Got '%d' free seats (%v)", free, err)
+	}
+}
+
+// Fires concurrent POST /guest_list/name requests with the same name on different tables
+// and checks only one of them adds the guest
+func TestConcurrentUniqueNames(t *testing.T) {
+	app := concurrentApp(t)
+	for i := 0; i < 4; i++ {
+		app.Store.AddTable(100)
+	}
+
+	const requests = 100
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			jsonStr := []byte(`{"table": ` + strconv.Itoa(i%4+1) + `, "accompanying_guests": 0}`)
+			req, _ := http.NewRequest("POST", "/guest_list/Twin"+strconv.Itoa(i%10), bytes.NewBuffer(jsonStr))
+			req.Header.Set("Content-Type", "application/json")
+
+			if response := executeRequestOn(app, req); response.Code == http.StatusCreated {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// a guest per name
+	if created != 10 {
+		t.Errorf("Expected 10 guests to be added. Got '%d'", created)
+	}
+	for i := 0; i < 10; i++ {
+		if guests, _ := app.Store.FindGuests("Twin" + strconv.Itoa(i)); len(guests) != 1 {
+			t.Errorf("Expected a single Twin%d. Got '%d'", i, len(guests))
+		}
+	}
+}
+
+// Fires concurrent moves of a guest between two tables and check-ins of the same guest
+// and checks none of them loses track of the guest while they move
+func TestConcurrentMovesAndCheckIns(t *testing.T) {
+	app := concurrentApp(t)
+	app.Store.AddTable(100)
+	app.Store.AddTable(100)
+	app.Store.AddGuest(&Guest{Name: "Mover", Table: 1})
+
+	const requests = 100
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	lost := 0
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest("POST", "/guests/Mover/move", bytes.NewBufferString(`{"table": `+strconv.Itoa(i%2+1)+`}`))
+			if i%3 == 0 {
+				req, _ = http.NewRequest("POST", "/guests/Mover/check_in", bytes.NewBufferString(`{"people": 1}`))
+			}
+
+			if response := executeRequestOn(app, req); response.Code == http.StatusNotFound {
+				mu.Lock()
+				lost++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if lost != 0 {
+		t.Errorf("Expected Mover to be found by every request. Got %d not found", lost)
+	}
+}
+
+// Tests handlerHealthz() GET /healthz and handlerReadyz() GET /readyz
+func TestHandlerHealth(t *testing.T) {
+	resetDB()
+
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"status":"ready"}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	// schema behind the build
+	if db != nil {
+		db.migrateDown(0)
+		defer db.migrateUp()
+
+		req, _ = http.NewRequest("GET", "/readyz", nil)
+		response = executeRequest(req)
+		checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+		if expected := `{"code":"schema_not_current","status":"unavailable"}`; response.Body.String() != expected {
+			t.Errorf("Expected response: `%s`\nGot: '%s'", expected, response.Body.String())
+		}
+
+		// never migrated, the probe doesn't create schema_version
+		if _, err := db.db.Exec("DROP TABLE schema_version"); err != nil {
+			t.Fatal(err)
+		}
+
+		response = executeRequest(req)
+		checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+		if expected := `{"code":"schema_not_current","status":"unavailable"}`; response.Body.String() != expected {
+			t.Errorf("Expected response: `%s`\nGot: '%s'", expected, response.Body.String())
+		}
+		if rows, err := db.db.Query("SELECT version FROM schema_version"); err == nil {
+			rows.Close()
+			t.Errorf("Expected schema_version not to be created by /readyz")
+		}
+	}
+
+	// database down, its error isn't answered
+	down := App{Store: &flakyStore{memoryStore: newMemoryStore(), failures: 1}}
+	response = httptest.NewRecorder()
+	down.handlerReadyz(response, req)
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+	if expected := `{"code":"database_unreachable","status":"unavailable"}`; response.Body.String() != expected {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expected, response.Body.String())
+	}
+}
+
+// Tests handlerGetTables() GET /venue and handlerGetTable() GET /venue/table
+func TestHandlerGetTables(t *testing.T) {
+	initializeDB()
+
+	addGuests(2, false) // 5 seats taken on table 2, 9 on table 3
+
+	req, _ := http.NewRequest("GET", "/venue", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"tables":[{"table_number":1,"seats":12,"seats_empty":12},{"table_number":2,"seats":12,"seats_empty":7},{"table_number":3,"seats":12,"seats_empty":3}]}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/venue/3", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse = `{"table_number":3,"seats":12,"seats_empty":3}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/venue/4", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// Tests handlerResizeTable() PATCH /venue/table
+func TestHandlerResizeTable(t *testing.T) {
+	initializeDB()
+
+	addGuests(2, false) // 9 seats taken on table 3
+
+	req, _ := http.NewRequest("PATCH", "/venue/3", bytes.NewBuffer([]byte(`{"seats": 9}`)))
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"table_number":3,"seats":9,"seats_empty":0}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	// below occupancy
+	req, _ = http.NewRequest("PATCH", "/venue/3", bytes.NewBuffer([]byte(`{"seats": 8}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/venue/3", bytes.NewBuffer([]byte(`{"seats": -1}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/venue/3", bytes.NewBuffer([]byte(`{"seats": 0}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/venue/4", bytes.NewBuffer([]byte(`{"seats": 4}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// Tests handlerDeleteTable() DELETE /venue/table
+func TestHandlerDeleteTable(t *testing.T) {
+	initializeDB()
+
+	addGuests(2, false) // TestGuest1 (5 seats) on table 2, TestGuest2 (9 seats) on table 3
+
+	// table with guests
+	req, _ := http.NewRequest("DELETE", "/venue/3", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	req, _ = http.NewRequest("DELETE", "/venue/3?guests=reassign", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"reassigned":[{"id":2,"name":"TestGuest2","table":1,"accompanying_guests":8}],"result":"success"}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	if g, _ := a.Store.GetGuest("TestGuest2"); g.Table != 1 {
+		t.Errorf("Expected TestGuest2 to be on table 1. Got '%d'", g.Table)
+	}
+
+	// TestGuest2 doesn't fit on table 2
+	req, _ = http.NewRequest("DELETE", "/venue/1?guests=reassign", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	req, _ = http.NewRequest("DELETE", "/venue/3", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("DELETE", "/venue/2?guests=maybe", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	// everyone at table 2 left, they stay on the guestlist with it
+	a.Store.UpdateGuest(&Guest{Name: "TestGuest1", AccompanyingGuests: 4})
+	a.Store.DepartGuest("TestGuest1")
+
+	req, _ = http.NewRequest("DELETE", "/venue/2", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if g, err := a.Store.GetGuest("TestGuest1"); err != nil || g.Table != 2 || g.TimeLeft == "" {
+		t.Errorf("Expected TestGuest1 kept as departed from table 2. Got %+v (%v)", g, err)
+	}
+}
+
+// Tests GET /venue/occupancy
+func TestHandlerOccupancy(t *testing.T) {
+	initializeDB()
+
+	addGuests(3, true) // TestGuest1 (5 seats, arrived) on table 2, TestGuest2 (9 seats) on table 3, TestGuest3 (1 seat, arrived) on table 1
+
+	req, _ := http.NewRequest("GET", "/venue/occupancy", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var o Occupancy
+	if err := json.Unmarshal(response.Body.Bytes(), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(o.Tables) != 3 {
+		t.Fatalf("Expected 3 tables. Got %d", len(o.Tables))
+	}
+
+	expected := []struct{ reserved, arrived, empty, emptyPresent int }{{1, 1, 11, 11}, {5, 5, 7, 7}, {9, 0, 3, 12}}
+	for i, e := range expected {
+		got := o.Tables[i]
+		if got.Number != i+1 || got.Capacity != 12 || got.Reserved != e.reserved || got.Arrived != e.arrived || got.SeatsEmpty != e.empty || got.SeatsEmptyPresent != e.emptyPresent {
+			t.Errorf("Unexpected occupancy for table %d: %+v", i+1, got)
+		}
+	}
+
+	venue := SeatCounts{Capacity: 36, Reserved: 15, Arrived: 6, SeatsEmpty: 21, SeatsEmptyPresent: 30}
+	if o.Venue != venue {
+		t.Errorf("Expected venue totals %+v. Got %+v", venue, o.Venue)
+	}
+
+	req, _ = http.NewRequest("GET", "/venue/occupancy?table_number=3", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"venue":{"capacity":12,"reserved_seats":9,"arrived":0,"seats_empty":3,"seats_empty_present":12},"tables":[{"table_number":3,"capacity":12,"reserved_seats":9,"arrived":0,"seats_empty":3,"seats_empty_present":12,"guests":[{"id":2,"name":"TestGuest2","accompanying_guests":8,"arrived":false,"present":0}]}]}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/venue/occupancy?table_number=2", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedBody := regexp.MustCompile(`^{"venue":{"capacity":12,"reserved_seats":5,"arrived":5,"seats_empty":7,"seats_empty_present":7},"tables":\[{"table_number":2,"capacity":12,"reserved_seats":5,"arrived":5,"seats_empty":7,"seats_empty_present":7,"guests":\[{"id":1,"name":"TestGuest1","accompanying_guests":4,"arrived":true,"present":5,"time_arrived":"[^"]+"}\]}\]}$`)
+	if !expectedBody.MatchString(response.Body.String()) {
+		t.Errorf("Unexpected response: '%s'", response.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/venue/occupancy?table_number=4", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("GET", "/venue/occupancy?table_number=two", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+// Tests partial check-ins and check-outs POST /guests/name/check_in|check_out and GET /guests/name/timeline
+func TestHandlerCheckInOut(t *testing.T) {
+	initializeDB()
+
+	addGuests(1, false) // TestGuest1 with 4 accompanying guests on table 2
+
+	checkInOut := func(event string, body string, code int) Guest {
+		req, _ := http.NewRequest("POST", "/guests/TestGuest1/"+event, bytes.NewBuffer([]byte(body)))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		g := Guest{}
+		json.Unmarshal(response.Body.Bytes(), &g)
+		return g
+	}
+
+	// the guest and two companions arrive, the other two come later
+	if g := checkInOut("check_in", `{"people":3}`, http.StatusOK); g.Arrived != 1 || g.Present != 3 || g.AccompanyingGuests != 4 {
+		t.Errorf("Expected 3 people present out of 5. Got %+v", g)
+	}
+
+	checkInOut("check_out", `{"people":4}`, http.StatusConflict)
+	checkInOut("check_out", `{"people":0}`, http.StatusBadRequest)
+
+	if g := checkInOut("check_out", `{"people":2}`, http.StatusOK); g.Present != 1 {
+		t.Errorf("Expected 1 person present. Got %+v", g)
+	}
+
+	// 4 companions join, one more than invited, taking a seat from the table
+	if g := checkInOut("check_in", `{"people":5}`, http.StatusOK); g.Present != 6 || g.AccompanyingGuests != 5 {
+		t.Errorf("Expected 6 people present. Got %+v", g)
+	}
+
+	if free, _ := a.Store.GetFreeSeats(2, false); free != 6 {
+		t.Errorf("Expected 6 free seats on table 2. Got '%d'", free)
+	}
+
+	checkInOut("check_in", `{"people":7}`, http.StatusConflict)
+
+	// the last of them going out is the guest leaving
+	if g := checkInOut("check_out", `{"people":6}`, http.StatusOK); g.Arrived != 0 || g.Present != 0 || g.TimeLeft == "" {
+		t.Errorf("Expected the guest to have left. Got %+v", g)
+	}
+
+	checkInOut("check_out", `{"people":1}`, http.StatusConflict)
+
+	req, _ := http.NewRequest("GET", "/guests/TestGuest1/timeline", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	timeline := Timeline{}
+	json.Unmarshal(response.Body.Bytes(), &timeline)
+
+	expected := []Movement{{"check_in", 3, 3, ""}, {"check_out", 2, 1, ""}, {"check_in", 5, 6, ""}, {"check_out", 6, 0, ""}}
+	if len(timeline.Movements) != len(expected) {
+		t.Fatalf("Expected %d movements. Got '%s'", len(expected), response.Body.String())
+	}
+
+	for i, m := range timeline.Movements {
+		if m.Event != expected[i].Event || m.People != expected[i].People || m.Present != expected[i].Present || m.Time == "" {
+			t.Errorf("Expected movement %+v. Got %+v", expected[i], m)
+		}
+	}
+
+	req, _ = http.NewRequest("POST", "/guests/Nobody/check_in", bytes.NewBuffer([]byte(`{"people":1}`)))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("GET", "/guests/Nobody/timeline", nil)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// Tests guests by id /v2/guests, with duplicate names
+func TestHandlerGuestsV2(t *testing.T) {
+	initializeDB()
+
+	request := func(method string, url string, body string, code int) Guest {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		g := Guest{}
+		json.Unmarshal(response.Body.Bytes(), &g)
+		return g
+	}
+
+	// two invitees with the same name
+	for id := 1; id <= 2; id++ {
+		if g := request("POST", "/v2/guests", `{"name":"John Smith","table":1,"accompanying_guests":1}`, http.StatusCreated); g.ID != id {
+			t.Errorf("Expected id %d. Got %+v", id, g)
+		}
+	}
+
+	request("POST", "/guest_list/John Smith", `{"table":2}`, http.StatusConflict)
+	request("POST", "/v2/guests", `{"table":2}`, http.StatusBadRequest)
+
+	req, _ := http.NewRequest("GET", "/v2/guests?name=John%20Smith", nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	expectedResponse := `{"guests":[{"id":1,"name":"John Smith","table":1,"accompanying_guests":1},{"id":2,"name":"John Smith","table":1,"accompanying_guests":1}]}`
+	if response.Body.String() != expectedResponse {
+		t.Errorf("Expected response: `%s`\nGot: '%s'", expectedResponse, response.Body.String())
+	}
+
+	// the name alone doesn't say who
+	request("GET", "/guests/John Smith", "", http.StatusConflict)
+	request("PUT", "/guests/John Smith", `{"accompanying_guests":1}`, http.StatusConflict)
+
+	// fixing the typo and moving the guest
+	if g := request("PATCH", "/v2/guests/2", `{"name":"Jon Smith","table":2}`, http.StatusOK); g.Name != "Jon Smith" || g.Table != 2 {
+		t.Errorf("Expected Jon Smith on table 2. Got %+v", g)
+	}
+
+	if g := request("GET", "/guests/John Smith", "", http.StatusOK); g.ID != 1 {
+		t.Errorf("Expected John Smith with id 1. Got %+v", g)
+	}
+
+	a.Store.AddGuest(&Guest{Name: "Big party", Table: 3, AccompanyingGuests: 10})
+
+	request("PATCH", "/v2/guests/1", `{"table":3}`, http.StatusConflict)
+	request("PATCH", "/v2/guests/1", `{"table":4}`, http.StatusNotFound)
+	request("PATCH", "/v2/guests/1", `{"name":""}`, http.StatusBadRequest)
+	request("PATCH", "/v2/guests/9", `{"name":"Nobody"}`, http.StatusNotFound)
+
+	if free, _ := a.Store.GetFreeSeats(1, false); free != 10 {
+		t.Errorf("Expected 10 free seats on table 1. Got '%d'", free)
+	}
+
+	request("DELETE", "/v2/guests/2", "", http.StatusOK)
+	request("GET", "/v2/guests/2", "", http.StatusNotFound)
+	request("DELETE", "/v2/guests/2", "", http.StatusNotFound)
+}
+
+// Tests moving and swapping parties POST /guests/name/move and POST /v2/guests/id/move
+func TestHandlerMoveGuest(t *testing.T) {
+	initializeDB()
+
+	addGuests(1, true) // TestGuest1 (5 seats, arrived) on table 2
+
+	move := func(url string, body string, code int) []Guest {
+		req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		gl := GuestList{}
+		json.Unmarshal(response.Body.Bytes(), &gl)
+		return gl.Guests
+	}
+
+	if guests := move("/guests/TestGuest1/move", `{"table":1}`, http.StatusOK); len(guests) != 1 || guests[0].Table != 1 || guests[0].Arrived != 1 {
+		t.Errorf("Expected TestGuest1 seated on table 1. Got %+v", guests)
+	}
+
+	// tables 1 and 3 are full
+	a.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 6})
+	a.Store.AddGuest(&Guest{Name: "B", Table: 3, AccompanyingGuests: 4})
+	a.Store.AddGuest(&Guest{Name: "C", Table: 3, AccompanyingGuests: 6})
+
+	move("/guests/TestGuest1/move", `{"table":3}`, http.StatusConflict)
+	move("/guests/TestGuest1/move", `{"swap_with":"C"}`, http.StatusConflict)
+
+	if guests := move("/guests/TestGuest1/move", `{"swap_with":"B"}`, http.StatusOK); len(guests) != 2 || guests[0].Table != 3 || guests[1].Table != 1 {
+		t.Errorf("Expected TestGuest1 and B to trade tables. Got %+v", guests)
+	}
+
+	for _, table := range []int{1, 3} {
+		if free, _ := a.Store.GetFreeSeats(table, false); free != 0 {
+			t.Errorf("Expected 0 free seats on table %d. Got '%d'", table, free)
+		}
+	}
+
+	// and back, by id
+	g, _ := a.Store.GetGuest("TestGuest1")
+	b, _ := a.Store.GetGuest("B")
+
+	if guests := move("/v2/guests/"+strconv.Itoa(g.ID)+"/move", `{"swap_with":`+strconv.Itoa(b.ID)+`}`, http.StatusOK); len(guests) != 2 || guests[0].Table != 1 || guests[1].Table != 3 {
+		t.Errorf("Expected TestGuest1 and B back on their tables. Got %+v", guests)
+	}
+
+	move("/guests/TestGuest1/move", `{"table":2,"swap_with":"B"}`, http.StatusBadRequest)
+	move("/guests/TestGuest1/move", `{}`, http.StatusBadRequest)
+	move("/guests/TestGuest1/move", `{"table":4}`, http.StatusNotFound)
+	move("/guests/TestGuest1/move", `{"swap_with":"Nobody"}`, http.StatusNotFound)
+	move("/v2/guests/99/move", `{"table":2}`, http.StatusNotFound)
+
+	// D left and their table was removed since
+	a.Store.AddGuest(&Guest{Name: "D", Table: 2})
+	a.Store.UpdateGuest(&Guest{Name: "D"})
+	a.Store.DepartGuest("D")
+	a.Store.DeleteTable(2, false)
+	d, _ := a.Store.GetGuest("D")
+
+	move("/v2/guests/"+strconv.Itoa(g.ID)+"/move", `{"swap_with":`+strconv.Itoa(d.ID)+`}`, http.StatusNotFound)
+}
+
+// Tests handlerAddGuest() picking the table POST /guest_list/name without a table
+func TestHandlerAddGuestAutoTable(t *testing.T) {
+	initializeDB()
+
+	addGuests(2, false) // 12 free seats on table 1, 7 on table 2, 3 on table 3
+
+	tests := []struct {
+		url      string
+		body     string
+		code     int
+		expected string
+	}{
+		{"/guest_list/Auto1?strategy=first_fit", `{"accompanying_guests":7}`, http.StatusCreated, `{"name":"Auto1","table_number":1}`},
+		{"/guest_list/Auto2", `{"accompanying_guests":2}`, http.StatusCreated, `{"name":"Auto2","table_number":3}`},
+		{"/guest_list/Auto3?strategy=balanced", `{"accompanying_guests":1}`, http.StatusCreated, `{"name":"Auto3","table_number":2}`},
+		{"/guest_list/Auto4?strategy=worst_fit", `{"accompanying_guests":1}`, http.StatusBadRequest, ""},
+		{"/guest_list/Auto4", `{"accompanying_guests":12}`, http.StatusConflict, ""},
+		{"/guest_list/Auto1", `{"accompanying_guests":0}`, http.StatusConflict, ""},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", test.url, bytes.NewBuffer([]byte(test.body)))
+		response := executeRequest(req)
+		checkResponseCode(t, test.code, response.Code)
+
+		if test.expected != "" && response.Body.String() != test.expected {
+			t.Errorf("Expected response: `%s`\nGot: '%s'", test.expected, response.Body.String())
+		}
+	}
+
+	if g, _ := a.Store.GetGuest("Auto3"); g.Table != 2 {
+		t.Errorf("Expected Auto3 to be on table 2. Got '%d'", g.Table)
+	}
+}
+
+// Tests handlerPlanSeating() POST /venue/plan
+func TestHandlerPlanSeating(t *testing.T) {
+	initializeDB()
+
+	a.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 3})
+	a.Store.AddGuest(&Guest{Name: "B", Table: 2, AccompanyingGuests: 1})
+	a.Store.AddGuest(&Guest{Name: "C", Table: 3, AccompanyingGuests: 1})
+	a.Store.AddGuest(&Guest{Name: "D", Table: 1})
+	a.Store.UpdateGuest(&Guest{Name: "A", AccompanyingGuests: 3})
+
+	body := `{"constraints":[
+		{"type":"together","guests":["A","B"]},
+		{"type":"apart","guests":["B","D"]},
+		{"type":"pinned","guests":["D"],"tables":[3]},
+		{"type":"together","guests":["C","Nobody"]}
+	]}`
+
+	plan := func(url string, code int) SeatingPlan {
+		req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		p := SeatingPlan{}
+		json.Unmarshal(response.Body.Bytes(), &p)
+		return p
+	}
+
+	// B joins A, who has arrived, and D leaves them for table 3
+	check := func(p SeatingPlan, applied bool) {
+		if p.Applied != applied || len(p.Moves) != 2 || p.Moves[0].Name != "B" || p.Moves[0].To != 1 || p.Moves[1].Name != "D" || p.Moves[1].To != 3 {
+			t.Errorf("Expected B moved to table 1 and D to table 3. Got %+v", p)
+		}
+		if len(p.Unsatisfied) != 1 || p.Unsatisfied[0].Constraint != 3 {
+			t.Errorf("Expected only constraint 3 unsatisfied. Got %+v", p.Unsatisfied)
+		}
+		if len(p.Tables) != 3 || p.Tables[0].SeatsEmpty != 6 || p.Tables[1].SeatsEmpty != 12 || p.Tables[2].SeatsEmpty != 9 {
+			t.Errorf("Expected 6, 12 and 9 free seats. Got %+v", p.Tables)
+		}
+	}
+
+	check(plan("/venue/plan", http.StatusOK), false)
+
+	if g, _ := a.Store.GetGuest("B"); g.Table != 2 {
+		t.Errorf("Expected B still on table 2. Got '%d'", g.Table)
+	}
+
+	check(plan("/venue/plan?apply=true", http.StatusOK), true)
+
+	for name, table := range map[string]int{"A": 1, "B": 1, "C": 3, "D": 3} {
+		if g, _ := a.Store.GetGuest(name); g.Table != table {
+			t.Errorf("Expected %s on table %d. Got '%d'", name, table, g.Table)
+		}
+	}
+
+	// nothing left to move
+	if p := plan("/venue/plan", http.StatusOK); len(p.Moves) != 0 {
+		t.Errorf("Expected no moves. Got %+v", p.Moves)
+	}
+
+	for _, bad := range []string{
+		`{"constraints":[{"type":"near","guests":["A","B"]}]}`,
+		`{"constraints":[{"type":"together","guests":["A"]}]}`,
+		`{"constraints":[{"type":"pinned","guests":["A"]}]}`,
+		`{"constraints":`,
+	} {
+		req, _ := http.NewRequest("POST", "/venue/plan", bytes.NewBuffer([]byte(bad)))
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+	}
+}
+
+// Tests handlerFits() GET /venue/fits
+func TestHandlerFits(t *testing.T) {
+	initializeDB()
+
+	a.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 9})
+	a.Store.AddGuest(&Guest{Name: "B", Table: 2, AccompanyingGuests: 7})
+	a.Store.AddGuest(&Guest{Name: "C", Table: 3, AccompanyingGuests: 3})
+	a.Store.UpdateGuest(&Guest{Name: "A", AccompanyingGuests: 9}) // 2 free seats on table 1, 4 on table 2, 8 on table 3
+
+	fits := func(url string, code int) Fits {
+		req, _ := http.NewRequest("GET", url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		f := Fits{}
+		json.Unmarshal(response.Body.Bytes(), &f)
+		return f
+	}
+
+	// only table 3 has room, table 2 would once B moves to table 3
+	f := fits("/venue/fits?party_size=7", http.StatusOK)
+	if len(f.Tables) != 1 || f.Tables[0].Number != 3 || f.Tables[0].SeatsLeft != 1 {
+		t.Errorf("Expected table 3 with 1 seat left. Got %+v", f.Tables)
+	}
+	if len(f.AfterReshuffle) != 1 || f.AfterReshuffle[0].Number != 2 || f.AfterReshuffle[0].SeatsLeft != 5 ||
+		len(f.AfterReshuffle[0].Moves) != 1 || f.AfterReshuffle[0].Moves[0].Name != "B" || f.AfterReshuffle[0].Moves[0].To != 3 {
+		t.Errorf("Expected table 2 after moving B to table 3. Got %+v", f.AfterReshuffle)
+	}
+
+	// preferred tables first, then best fit
+	f = fits("/venue/fits?party_size=4&preferred=3", http.StatusOK)
+	if len(f.Tables) != 2 || f.Tables[0].Number != 3 || !f.Tables[0].Preferred || f.Tables[1].Number != 2 || f.Tables[1].SeatsLeft != 0 {
+		t.Errorf("Expected tables 3 and 2. Got %+v", f.Tables)
+	}
+
+	// A has arrived and stays, C can make room on table 3 by moving to table 2
+	f = fits("/venue/fits?party_size=9", http.StatusOK)
+	if len(f.AfterReshuffle) != 2 || f.AfterReshuffle[0].Number != 2 || f.AfterReshuffle[1].Number != 3 || f.AfterReshuffle[1].SeatsLeft != 3 ||
+		len(f.AfterReshuffle[1].Moves) != 1 || f.AfterReshuffle[1].Moves[0].Name != "C" || f.AfterReshuffle[1].Moves[0].To != 2 {
+		t.Errorf("Expected tables 2 and 3 after a reshuffle. Got %+v", f.AfterReshuffle)
+	}
+
+	if f = fits("/venue/fits?party_size=13", http.StatusOK); len(f.Tables) != 0 || len(f.AfterReshuffle) != 0 {
+		t.Errorf("Expected no table for 13. Got %+v", f)
+	}
+
+	fits("/venue/fits", http.StatusBadRequest)
+	fits("/venue/fits?party_size=0", http.StatusBadRequest)
+	fits("/venue/fits?party_size=2&preferred=one", http.StatusBadRequest)
+	fits("/venue/fits?party_size=2&preferred=9", http.StatusNotFound)
+}
+
+// Tests queueing guests on the waitlist and promoting them as seats free up
+func TestHandlerWaitlist(t *testing.T) {
+	initializeDB()
+
+	a.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 11}) // table 1 is full
+
+	request := func(method string, url string, body string, code int) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+		return response
+	}
+
+	waitlist := func(url string) []string {
+		w := Waitlist{}
+		json.Unmarshal(request("GET", url, "", http.StatusOK).Body.Bytes(), &w)
+
+		names := []string{}
+		for _, e := range w.Entries {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	request("POST", "/guest_list/W1?waitlist=true&priority=1", `{"table":1,"accompanying_guests":3}`, http.StatusAccepted)
+	request("POST", "/v2/guests?waitlist=true&priority=5", `{"name":"W2","table":1,"accompanying_guests":7}`, http.StatusAccepted)
+	request("POST", "/guest_list/W3?waitlist=true", `{"table":1,"accompanying_guests":0}`, http.StatusAccepted)
+	request("POST", "/guest_list/A?waitlist=true", `{"table":1,"accompanying_guests":0}`, http.StatusConflict)
+	request("POST", "/guest_list/W4?waitlist=true&priority=high", `{"table":1,"accompanying_guests":0}`, http.StatusBadRequest)
+	request("POST", "/guest_list/W4", `{"table":1,"accompanying_guests":0}`, http.StatusConflict)
+
+	if names := waitlist("/waitlist"); strings.Join(names, ",") != "W2,W1,W3" {
+		t.Errorf("Expected W2, W1 and W3 waiting. Got %v", names)
+	}
+
+	// A's 12 seats take W2 and W1, W3 doesn't fit anymore
+	request("DELETE", "/guest_list/A", "", http.StatusOK)
+
+	if names := waitlist("/waitlist"); strings.Join(names, ",") != "W3" {
+		t.Errorf("Expected W3 waiting. Got %v", names)
+	}
+	if names := waitlist("/waitlist?promoted=true"); len(names) != 2 {
+		t.Errorf("Expected W2 and W1 promoted. Got %v", names)
+	}
+	for _, name := range []string{"W1", "W2"} {
+		if g, err := a.Store.GetGuest(name); err != nil || g.Table != 1 {
+			t.Errorf("Expected %s on table 1. Got %+v (%v)", name, g, err)
+		}
+	}
+
+	// a bigger table makes room for W3
+	request("PATCH", "/venue/1", `{"seats":13}`, http.StatusOK)
+
+	if names := waitlist("/waitlist"); len(names) != 0 {
+		t.Errorf("Expected nobody waiting. Got %v", names)
+	}
+	if g, err := a.Store.GetGuest("W3"); err != nil || g.Table != 1 {
+		t.Errorf("Expected W3 on table 1. Got %+v (%v)", g, err)
+	}
+
+	// W2 arriving with fewer people makes room for W5
+	request("POST", "/guest_list/W5?waitlist=true", `{"table":1,"accompanying_guests":1}`, http.StatusAccepted)
+	request("PUT", "/guests/W2", `{"accompanying_guests":5}`, http.StatusOK)
+
+	if names := waitlist("/waitlist"); len(names) != 0 {
+		t.Errorf("Expected nobody waiting. Got %v", names)
+	}
+	if g, err := a.Store.GetGuest("W5"); err != nil || g.Table != 1 {
+		t.Errorf("Expected W5 on table 1. Got %+v (%v)", g, err)
+	}
+	if promoted, err := a.Store.PromoteWaitlist(); err != nil || len(promoted) != 0 {
+		t.Errorf("Expected nothing to promote. Got %+v (%v)", promoted, err)
+	}
+
+	e := WaitlistEntry{}
+	json.Unmarshal(request("POST", "/guest_list/W4?waitlist=true", `{"table":1,"accompanying_guests":0}`, http.StatusAccepted).Body.Bytes(), &e)
+
+	request("DELETE", "/waitlist/"+strconv.Itoa(e.ID), "", http.StatusOK)
+	request("DELETE", "/waitlist/"+strconv.Itoa(e.ID), "", http.StatusNotFound)
+	request("DELETE", "/waitlist/1", "", http.StatusNotFound) // promoted entries stay as a record
+}
+
+// Store turning every add away as if the table had filled up, though its seats are free
+type fullStore struct {
+	*memoryStore
+}
+
+func (s fullStore) AddGuest(g *Guest) error {
+	return ErrTableFull
+}
+
+// Tests a guest queued after the seats freed up is promoted right away instead of waiting for the next departure
+func TestHandlerWaitlistSeatsFreedMeanwhile(t *testing.T) {
+	app := &App{}
+	app.Init(fullStore{newMemoryStore()})
+	app.Store.AddTable(12)
+
+	req, _ := http.NewRequest("POST", "/guest_list/Late?waitlist=true", bytes.NewBufferString(`{"table":1,"accompanying_guests":3}`))
+	response := executeRequestOn(app, req)
+	checkResponseCode(t, http.StatusAccepted, response.Code)
+
+	e := WaitlistEntry{}
+	json.Unmarshal(response.Body.Bytes(), &e)
+	if e.PromotedAt == "" || e.GuestID == 0 || e.Table != 1 {
+		t.Errorf("Expected the entry promoted to table 1. Got %+v", e)
+	}
+	if waiting, _ := app.Store.GetWaitlist(false); len(waiting) != 0 {
+		t.Errorf("Expected nobody waiting. Got %+v", waiting)
+	}
+	if g, err := app.Store.GetGuest("Late"); err != nil || g.Table != 1 {
+		t.Errorf("Expected Late on table 1. Got %+v (%v)", g, err)
+	}
+}
+
+// Store wrapping the errors of adds with what it was doing
+type wrappingStore struct {
+	*memoryStore
+}
+
+func (s wrappingStore) AddGuest(g *Guest) error {
+	if err := s.memoryStore.AddGuest(g); err != nil {
+		return fmt.Errorf("adding %s: %w", g.Name, err)
+	}
+
+	return nil
+}
+
+func (s wrappingStore) InviteGuest(g *Guest) error {
+	if err := s.memoryStore.InviteGuest(g); err != nil {
+		return fmt.Errorf("inviting %s: %w", g.Name, err)
+	}
+
+	return nil
+}
+
+// Tests wrapped model errors are answered like the bare ones
+func TestHandlerWrappedErrors(t *testing.T) {
+	app := &App{}
+	app.Init(wrappingStore{newMemoryStore()})
+	app.Store.AddTable(4)
+	app.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 3})
+
+	request := func(url string, body string, code int) {
+		req, _ := http.NewRequest("POST", url, bytes.NewBufferString(body))
+		checkResponseCode(t, code, executeRequestOn(app, req).Code)
+	}
+
+	request("/guest_list/W1?waitlist=true", `{"table":1,"accompanying_guests":0}`, http.StatusAccepted)
+	request("/v2/guests?waitlist=true", `{"name":"W2","table":1,"accompanying_guests":0}`, http.StatusAccepted)
+	request("/guest_list/B", `{"table":1,"accompanying_guests":0}`, http.StatusConflict)
+
+	if waiting, _ := app.Store.GetWaitlist(false); len(waiting) != 2 {
+		t.Errorf("Expected W1 and W2 waiting. Got %+v", waiting)
+	}
+}
+
+// Tests handlerImportGuests() POST /v2/guests/import
+func TestHandlerImportGuests(t *testing.T) {
+	initializeDB()
+
+	request := func(url string, contentType string, body string, code int) ImportReport {
+		req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", contentType)
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		report := ImportReport{}
+		json.Unmarshal(response.Body.Bytes(), &report)
+		return report
+	}
+
+	statuses := func(report ImportReport) string {
+		s := []string{}
+		for _, row := range report.Rows {
+			s = append(s, row.Status)
+		}
+		return strings.Join(s, ",")
+	}
+
+	// I3 doesn't fit on table 1 after I1 and I2
+	body := `[
+		{"name":"I1","table":1,"accompanying_guests":5},
+		{"name":"I2","table":1,"accompanying_guests":5},
+		{"name":"I3","table":1,"accompanying_guests":0}
+	]`
+
+	report := request("/v2/guests/import", "application/json", body, http.StatusConflict)
+	if report.Imported != 0 || report.Failed != 1 || statuses(report) != "valid,valid,failed" {
+		t.Errorf("Expected nothing imported and I3 failed. Got %+v", report)
+	}
+	if gl, _ := a.Store.GetGuestList(); len(gl.Guests) != 0 {
+		t.Errorf("Expected an empty guest list. Got %+v", gl.Guests)
+	}
+
+	report = request("/v2/guests/import?mode=best_effort", "application/json", body, http.StatusOK)
+	if report.Imported != 2 || report.Failed != 1 || statuses(report) != "imported,imported,failed" || report.Rows[0].ID == 0 {
+		t.Errorf("Expected I1 and I2 imported. Got %+v", report)
+	}
+	if g, err := a.Store.GetGuest("I2"); err != nil || g.Table != 1 {
+		t.Errorf("Expected I2 on table 1. Got %+v (%v)", g, err)
+	}
+
+	csv := "Name,Table,Accompanying_Guests,Notes\nC1,,3,vip\nC2,x,0,\n,2,1\n"
+	report = request("/v2/guests/import?mode=validate", "text/csv; charset=utf-8", csv, http.StatusOK)
+	if report.Imported != 0 || report.Failed != 2 || statuses(report) != "valid,failed,failed" || report.Rows[0].Table != 2 || report.Rows[1].Error != "table must be a number" {
+		t.Errorf("Expected C1 valid on table 2 and two failed rows. Got %+v", report)
+	}
+	if _, err := a.Store.GetGuest("C1"); err != ErrGuestNotFound {
+		t.Errorf("Expected C1 not imported. Got '%v'", err)
+	}
+
+	// a name too long for the database fails its row only
+	long := strings.Repeat("é", maxNameLength+1)
+	report = request("/v2/guests/import?mode=best_effort", "application/json", `[{"name":"`+long+`","table":2},{"name":"L1","table":2}]`, http.StatusOK)
+	if statuses(report) != "failed,imported" || report.Rows[0].Error != "name can't be longer than 64 characters" {
+		t.Errorf("Expected the long name refused. Got %+v", report)
+	}
+	if _, err := a.Store.GetGuest(long[:2*maxNameLength]); err != ErrGuestNotFound {
+		t.Errorf("Expected the long name not imported, not even cut short. Got '%v'", err)
+	}
+
+	report = request("/v2/guests/import?unique=true&mode=best_effort", "application/json", `[{"name":"I1","table":2},{"name":"U1"}]`, http.StatusOK)
+	if statuses(report) != "failed,imported" || report.Rows[0].Error != ErrDuplicateGuest.Error() {
+		t.Errorf("Expected I1 refused as a duplicate. Got %+v", report)
+	}
+
+	request("/v2/guests/import?mode=maybe", "application/json", body, http.StatusBadRequest)
+	request("/v2/guests/import", "application/json", `{"name":"I1"}`, http.StatusBadRequest)
+	request("/v2/guests/import", "text/csv", "guest,table\nA,1\n", http.StatusBadRequest)
+}
+
+// Tests the CSV, JSON Lines and HTML exports of GET /guest_list and GET /guests
+func TestHandlerExports(t *testing.T) {
+	initializeDB()
+
+	addGuests(3, true) // TestGuest1 and TestGuest3 arrived
+
+	export := func(url string, accept string, code int, contentType string) string {
+		req, _ := http.NewRequest("GET", url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		if got := response.Header().Get("Content-Type"); contentType != "" && !strings.HasPrefix(got, contentType) {
+			t.Errorf("Expected Content-Type %s. Got '%s'", contentType, got)
+		}
+		return response.Body.String()
+	}
+
+	lines := strings.Split(strings.TrimSpace(export("/guest_list?format=csv", "", http.StatusOK, "text/csv")), "\n")
+	if len(lines) != 4 || lines[0] != "id,name,table,accompanying_guests,arrived,present,time_arrived,time_left" ||
+		!strings.HasPrefix(lines[1], "1,TestGuest1,2,4,true,5,") || lines[2] != "2,TestGuest2,3,8,false,0,," {
+		t.Errorf("Unexpected CSV export:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// same as ?format=jsonl
+	lines = strings.Split(strings.TrimSpace(export("/guests", "application/x-ndjson", http.StatusOK, "application/x-ndjson")), "\n")
+	names := []string{}
+	for _, line := range lines {
+		var g exportGuest
+		if err := json.Unmarshal([]byte(line), &g); err != nil || g.TimeArrived == "" {
+			t.Errorf("Expected an arrived guest per line. Got '%s' (%v)", line, err)
+		}
+		names = append(names, g.Name)
+	}
+	if strings.Join(names, ",") != "TestGuest1,TestGuest3" {
+		t.Errorf("Expected TestGuest1 and TestGuest3 exported. Got %v", names)
+	}
+
+	html := export("/guest_list", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusOK, "text/html")
+	if !strings.Contains(html, "Guest list - Table 3") || !strings.Contains(html, "<td>TestGuest2</td>") {
+		t.Errorf("Expected a seating sheet with TestGuest2 on table 3. Got:\n%s", html)
+	}
+	if html := export("/guests?format=html", "", http.StatusOK, "text/html"); strings.Contains(html, "TestGuest2") {
+		t.Errorf("Expected only arrived guests on the sheet. Got:\n%s", html)
+	}
+
+	export("/guest_list", "application/json", http.StatusOK, "application/json")
+	export("/guest_list?format=xml", "", http.StatusBadRequest, "")
+}
+
+// Tests pagination, filters and sorting of GET /guest_list and GET /guests
+func TestHandlerGuestPages(t *testing.T) {
+	initializeDB()
+
+	for _, g := range []Guest{{Name: "Ann", Table: 1}, {Name: "Bob", Table: 2, AccompanyingGuests: 3}, {Name: "Abe", Table: 1, AccompanyingGuests: 5}, {Name: "Cat", Table: 3, AccompanyingGuests: 1}, {Name: "Al", Table: 2}} {
+		a.Store.AddGuest(&g)
+	}
+	a.Store.UpdateGuest(&Guest{Name: "Bob", AccompanyingGuests: 3})
+	a.Store.UpdateGuest(&Guest{Name: "Abe", AccompanyingGuests: 5})
+
+	page := func(url string, code int) GuestPage {
+		req, _ := http.NewRequest("GET", url, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		p := GuestPage{}
+		json.Unmarshal(response.Body.Bytes(), &p)
+		return p
+	}
+
+	// names on every page of url, following the cursors
+	walk := func(url string) (string, int) {
+		names := []string{}
+		p := page(url, http.StatusOK)
+		total := p.Total
+
+		for pages := 0; pages < 10; pages++ {
+			for _, g := range p.Guests {
+				names = append(names, g.Name)
+			}
+			if p.NextCursor == "" {
+				break
+			}
+			p = page(url+"&cursor="+p.NextCursor, http.StatusOK)
+		}
+		return strings.Join(names, ","), total
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+		total    int
+	}{
+		{"/guest_list?limit=2", "Ann,Bob,Abe,Cat,Al", 5},
+		{"/guest_list?sort=name&limit=2", "Abe,Al,Ann,Bob,Cat", 5},
+		{"/guest_list?sort=table&limit=3", "Ann,Abe,Bob,Al,Cat", 5},
+		{"/guest_list?sort=time_arrived&limit=1", "Bob,Abe,Ann,Cat,Al", 5},
+		{"/guest_list?name_prefix=A&sort=name", "Abe,Al,Ann", 3},
+		{"/guest_list?table=2", "Bob,Al", 2},
+		{"/guest_list?min_party=2&max_party=4", "Bob,Cat", 2},
+		{"/guest_list?arrived=false&limit=1", "Ann,Cat,Al", 3},
+		{"/guest_list?arrived_after=2000-01-01T00:00:00Z", "Bob,Abe", 2},
+		{"/guest_list?arrived_after=2100-01-01T00:00:00Z", "", 0},
+		{"/guests?sort=name&limit=1", "Abe,Bob", 2},
+		{"/guests?table=1", "Abe", 1},
+	}
+
+	for _, test := range tests {
+		if names, total := walk(test.url); names != test.expected || total != test.total {
+			t.Errorf("%s: expected %s (%d in total). Got %s (%d)", test.url, test.expected, test.total, names, total)
+		}
+	}
+
+	byName := page("/guest_list?sort=name&limit=1", http.StatusOK)
+
+	for _, url := range []string{
+		"/guest_list?limit=0",
+		"/guest_list?limit=1001",
+		"/guest_list?sort=age",
+		"/guest_list?arrived=maybe",
+		"/guest_list?table=one",
+		"/guest_list?arrived_after=yesterday",
+		"/guest_list?cursor=garbage",
+		"/guest_list?cursor=" + byName.NextCursor,
+	} {
+		page(url, http.StatusBadRequest)
+	}
+}
+
+// Tests that failures are answered as problem+json with their status and code
+func TestHandlerProblems(t *testing.T) {
+	initializeDB()
+	a.Store.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 9})
+
+	problem := func(method string, url string, body string, code int) Problem {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		response := executeRequest(req)
+		checkResponseCode(t, code, response.Code)
+
+		if contentType := response.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("%s %s: expected application/problem+json. Got '%s'", method, url, contentType)
+		}
+
+		p := Problem{}
+		json.Unmarshal(response.Body.Bytes(), &p)
+		if p.Status != code || p.Type != "about:blank" || p.Title != http.StatusText(code) {
+			t.Errorf("%s %s: expected a %d problem. Got %+v", method, url, code, p)
+		}
+		return p
+	}
+
+	// removing a guest who isn't on the list
+	if p := problem("DELETE", "/guest_list/Nobody", "", http.StatusNotFound); p.Code != "guest_not_found" {
+		t.Errorf("Expected guest_not_found. Got %+v", p)
+	}
+	if p := problem("PUT", "/guests/Nobody", `{"accompanying_guests": 0}`, http.StatusNotFound); p.Code != "guest_not_found" {
+		t.Errorf("Expected guest_not_found. Got %+v", p)
+	}
+	if p := problem("POST", "/guest_list/B", `{"table": 9, "accompanying_guests": 0}`, http.StatusNotFound); p.Code != "table_not_found" {
+		t.Errorf("Expected table_not_found. Got %+v", p)
+	}
+	if p := problem("POST", "/guest_list/B", `{"table": 1, "accompanying_guests": 2}`, http.StatusConflict); p.Code != "table_full" {
+		t.Errorf("Expected table_full. Got %+v", p)
+	}
+	if p := problem("POST", "/guest_list/A", `{"table": 2, "accompanying_guests": 0}`, http.StatusConflict); p.Code != "duplicate_guest" {
+		t.Errorf("Expected duplicate_guest. Got %+v", p)
+	}
+
+	// validation errors name what's wrong
+	if p := problem("POST", "/venue", `{"seats": 0}`, http.StatusBadRequest); p.Code != "invalid_parameter" || p.Param != "seats" {
+		t.Errorf("Expected invalid seats. Got %+v", p)
+	}
+	if p := problem("GET", "/guest_list?limit=0", "", http.StatusBadRequest); p.Code != "invalid_parameter" || p.Param != "limit" {
+		t.Errorf("Expected invalid limit. Got %+v", p)
+	}
+	if p := problem("POST", "/v2/guests", `{"name": `, http.StatusBadRequest); p.Code != "invalid_payload" {
+		t.Errorf("Expected invalid_payload. Got %+v", p)
+	}
+
+	// negative parties and tables, on v1 and v2 and on arrival
+	negative := []struct {
+		method, url, body, param string
+	}{
+		{"POST", "/guest_list/B", `{"table": 2, "accompanying_guests": -10}`, "accompanying_guests"},
+		{"POST", "/guest_list/B", `{"table": -1, "accompanying_guests": 0}`, "table"},
+		{"POST", "/v2/guests", `{"name": "B", "table": 2, "accompanying_guests": -10}`, "accompanying_guests"},
+		{"POST", "/v2/guests", `{"name": "B", "table": -1}`, "table"},
+		{"POST", "/v2/guests", `{"name": " "}`, "name"},
+		{"PATCH", "/v2/guests/1", `{"name": " \t"}`, "name"},
+		{"POST", "/v2/guests", `{"name": "` + strings.Repeat("x", maxNameLength+1) + `"}`, "name"},
+		{"PUT", "/guests/A", `{"accompanying_guests": -50}`, "accompanying_guests"},
+	}
+	for _, c := range negative {
+		if p := problem(c.method, c.url, c.body, http.StatusBadRequest); p.Code != "invalid_parameter" || p.Param != c.param {
+			t.Errorf("%s %s %s: expected invalid %s. Got %+v", c.method, c.url, c.body, c.param, p)
+		}
+	}
+
+	if seats, _ := a.Store.GetFreeSeats(0, true); seats != 26 {
+		t.Errorf("Expected the 26 free seats left by A. Got '%d'", seats)
+	}
+
+	// seats running out anywhere but on an add say what didn't fit
+	a.Store.AddGuest(&Guest{Name: "C", Table: 2, AccompanyingGuests: 4})
+	a.Store.AddGuest(&Guest{Name: "D", Table: 3, AccompanyingGuests: 4})
+
+	if p := problem("PUT", "/guests/A", `{"accompanying_guests": 12}`, http.StatusConflict); p.Code != "party_too_big" {
+		t.Errorf("Expected party_too_big. Got %+v", p)
+	}
+	if p := problem("POST", "/guests/A/move", `{"table": 2}`, http.StatusConflict); p.Code != "no_room_to_move" {
+		t.Errorf("Expected no_room_to_move. Got %+v", p)
+	}
+	if p := problem("DELETE", "/venue/1?guests=reassign", "", http.StatusConflict); p.Code != "no_room_to_reassign" || p.Detail != "no table can take the reassigned guests" {
+		t.Errorf("Expected no_room_to_reassign. Got %+v", p)
+	}
+}
@@ -0,0 +1,305 @@
+// migrate.go
+
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema migrations for every dialect, migrations/<dialect>/<version>_<name>.(up|down).sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// A single schema change and how to revert it
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// Loads the migrations of dialect d, sorted by version
+func loadMigrations(d dialect) ([]migration, error) {
+	dir := path.Join("migrations", d.name)
+
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+
+	for _, e := range entries {
+		// 0001_init.up.sql -> "0001", "init.up.sql"
+		parts := strings.SplitN(e.Name(), "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if len(parts) != 2 || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version}
+			byVersion[version] = m
+		}
+
+		switch {
+		case strings.HasSuffix(parts[1], ".up.sql"):
+			m.name = strings.TrimSuffix(parts[1], ".up.sql")
+			m.up = string(content)
+		case strings.HasSuffix(parts[1], ".down.sql"):
+			m.down = string(content)
+		default:
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+	}
+
+	migrations := []migration{}
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d is missing its up or down file", m.version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+// Brings the schema of store up to date, refusing to run on an unknown schema version
+// The memory backend has no schema
+func migrateStore(store Store) error {
+	s, ok := store.(*sqlStore)
+	if !ok {
+		return nil
+	}
+
+	return s.migrateUp()
+}
+
+// Latest known schema version, 0 if there are no migrations
+func (s *sqlStore) latestVersion() (int, error) {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+
+	return migrations[len(migrations)-1].version, nil
+}
+
+// Current schema version of the database, 0 on an empty database
+func (s *sqlStore) schemaVersion() (int, error) {
+	if _, err := s.db.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INT NOT NULL PRIMARY KEY, applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)"); err != nil {
+		return 0, err
+	}
+
+	return s.appliedVersion()
+}
+
+// Latest version on the schema_version table, read only: fails if the table doesn't exist
+func (s *sqlStore) appliedVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow("SELECT version FROM schema_version ORDER BY version DESC LIMIT 1").Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return version, err
+}
+
+// Fails if the database was migrated by a newer version of the app
+func (s *sqlStore) checkSchema() (int, error) {
+	version, err := s.schemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	latest, err := s.latestVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	if version > latest {
+		return version, fmt.Errorf("unknown schema version %d, this build knows up to version %d", version, latest)
+	}
+
+	return version, nil
+}
+
+// Fails unless the database is at the latest known schema version, without writing to it (readiness probes run it)
+func (s *sqlStore) checkSchemaCurrent() error {
+	version, err := s.appliedVersion()
+	if err != nil {
+		return err
+	}
+
+	latest, err := s.latestVersion()
+	if err != nil {
+		return err
+	}
+
+	if version != latest {
+		return fmt.Errorf("schema version %d, expected %d", version, latest)
+	}
+
+	return nil
+}
+
+// Applies every pending migration
+func (s *sqlStore) migrateUp() error {
+	version, err := s.checkSchema()
+	if err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		err := s.withTx(func(tx *sql.Tx) error {
+			if err := execScript(tx, m.up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.rebind("INSERT INTO schema_version (version) VALUES (?)"), m.version)
+			return err
+		})
+
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %v", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Reverts migrations until the schema is at version target (0 reverts everything)
+func (s *sqlStore) migrateDown(target int) error {
+	version, err := s.checkSchema()
+	if err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version > version || m.version <= target {
+			continue
+		}
+
+		err := s.withTx(func(tx *sql.Tx) error {
+			if err := execScript(tx, m.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(s.rebind("DELETE FROM schema_version WHERE version = ?"), m.version)
+			return err
+		})
+
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %v", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Executes every ;-terminated statement of script, drivers don't agree on multi-statement support
+// Statements must not contain ; in literals or comments
+func execScript(tx *sql.Tx, script string) error {
+	for _, statement := range strings.Split(script, ";") {
+		if isComment(statement) {
+			continue
+		}
+
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Whether a statement of a script is blank or only -- comments, which some drivers refuse to run
+func isComment(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Handles the "migrate" command: "up", "down [version]" (one step by default) and "version"
+func migrateCommand(store Store, args []string) error {
+	s, ok := store.(*sqlStore)
+	if !ok {
+		return errors.New("the memory backend has no schema to migrate")
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [version] | version")
+	}
+
+	switch args[0] {
+	case "up":
+		if err := s.migrateUp(); err != nil {
+			return err
+		}
+
+	case "down":
+		version, err := s.schemaVersion()
+		if err != nil {
+			return err
+		}
+
+		target := version - 1
+		if len(args) > 1 {
+			if target, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid version %q", args[1])
+			}
+		}
+
+		if err := s.migrateDown(target); err != nil {
+			return err
+		}
+
+	case "version":
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	version, err := s.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("schema version %d\n", version)
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// migrate_test.go
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Every dialect must ship the same schema versions
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	expected, err := loadMigrations(mysqlDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []dialect{postgresDialect, sqliteDialect} {
+		migrations, err := loadMigrations(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(migrations) != len(expected) {
+			t.Fatalf("Expected %d %s migrations. Got '%d'", len(expected), d.name, len(migrations))
+		}
+
+		for i := range migrations {
+			if migrations[i].version != expected[i].version || migrations[i].name != expected[i].name {
+				t.Errorf("Expected %s migration %04d_%s. Got '%04d_%s'", d.name, expected[i].version, expected[i].name, migrations[i].version, migrations[i].name)
+			}
+		}
+	}
+}
+
+// Tests migrating up and down, and refusing to run on an unknown schema version
+func TestMigrateSQLite(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "guestlist_migrate_test.db")
+	os.Remove(path)
+	defer os.Remove(path)
+
+	s, err := newSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.migrateUp(); err != nil {
+		t.Skipf("sqlite unavailable: %v", err)
+	}
+
+	latest, _ := s.latestVersion()
+	if version, _ := s.schemaVersion(); version != latest {
+		t.Errorf("Expected schema version %d. Got '%d'", latest, version)
+	}
+
+	if err := s.migrateDown(0); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := s.schemaVersion(); version != 0 {
+		t.Errorf("Expected schema version 0. Got '%d'", version)
+	}
+
+	// a newer build migrated the database
+	s.db.Exec("INSERT INTO schema_version (version) VALUES (?)", latest+1)
+
+	if err := s.migrateUp(); err == nil {
+		t.Errorf("Expected an error on unknown schema version %d", latest+1)
+	}
+}
+
+// Tests that rebuilding guestlist on sqlite (0004_guest_ids) keeps guests, their movements and ids
+func TestMigrateSQLiteKeepsGuests(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "guestlist_rebuild_test.db")
+	os.Remove(path)
+	defer os.Remove(path)
+
+	s, err := newSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.migrateUp(); err != nil {
+		t.Skipf("sqlite unavailable: %v", err)
+	}
+
+	s.AddTable(10)
+	s.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 2})
+	s.AddGuest(&Guest{Name: "B", Table: 1})
+	s.UpdateGuest(&Guest{Name: "A", AccompanyingGuests: 2})
+	s.DeleteGuest("B") // id 2 must not be given again
+
+	// back to the UNIQUE names and up again
+	for _, migrate := range []func() error{func() error { return s.migrateDown(3) }, s.migrateUp} {
+		if err := migrate(); err != nil {
+			t.Fatal(err)
+		}
+
+		g, err := s.GetGuest("A")
+		if err != nil || g.ID != 1 || g.Arrived != 1 || g.Present != 3 {
+			t.Errorf("Expected arrived guest A with id 1. Got %+v (%v)", g, err)
+		}
+
+		if movements, _ := s.GetMovements("A"); len(movements) != 1 {
+			t.Errorf("Expected 1 movement. Got '%d'", len(movements))
+		}
+	}
+
+	g := Guest{Name: "A", Table: 1}
+	if err := s.InviteGuest(&g); err != nil || g.ID != 3 {
+		t.Errorf("Expected a second guest A with id 3. Got %+v (%v)", g, err)
+	}
+}
+
+// Tests adopting a database built from the old docker dump, with time_arrived as MySQL 5.7 made it
+// Needs APP_BACKEND=mysql, the schema of the test database is rebuilt
+func TestMigrateMySQLDumpSchema(t *testing.T) {
+	if db == nil || db.dialect.name != "mysql" {
+		t.Skip("needs APP_BACKEND=mysql")
+	}
+	defer resetDB()
+
+	if err := db.migrateDown(0); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := []string{
+		"DROP TABLE IF EXISTS schema_version",
+		"CREATE TABLE venue (table_number INT NOT NULL auto_increment, seats INT NOT NULL DEFAULT 6, PRIMARY KEY (table_number))",
+		`CREATE TABLE guestlist (id INT NOT NULL auto_increment, guest_name VARCHAR (64) CHARACTER SET utf8 UNIQUE, table_number INT NOT NULL,
+			accompanying_guests INT NOT NULL, time_arrived TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, arrived BOOLEAN DEFAULT FALSE,
+			PRIMARY KEY (id), FOREIGN KEY (table_number) REFERENCES venue(table_number))`,
+		"INSERT INTO venue (seats) VALUES (10)",
+		"INSERT INTO guestlist (guest_name, table_number, accompanying_guests) VALUES ('Early', 1, 0)",
+		"INSERT INTO guestlist (guest_name, table_number, accompanying_guests, time_arrived, arrived) VALUES ('Here', 1, 1, '2021-06-01 18:00:00', TRUE)",
+	}
+	for _, statement := range dump {
+		if _, err := db.db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.migrateUp(); err != nil {
+		t.Fatal(err)
+	}
+
+	// guests yet to arrive have no arrival time
+	if g, err := db.GetGuest("Early"); err != nil || g.TimeArrived != "" {
+		t.Errorf("Expected Early without time_arrived. Got %+v (%v)", g, err)
+	}
+
+	here, err := db.GetGuest("Here")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updating a guest keeps their arrival time
+	if _, err := db.CheckIn("Here", 1); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := db.GetGuest("Here"); g.TimeArrived != here.TimeArrived {
+		t.Errorf("Expected time_arrived %s to be kept. Got '%s'", here.TimeArrived, g.TimeArrived)
+	}
+	if g, _ := db.GetGuest("Early"); g.TimeArrived != "" {
+		t.Errorf("Expected Early without time_arrived after an update. Got '%s'", g.TimeArrived)
+	}
+}
@@ -0,0 +1,21 @@
+-- Seats and accompanying guests are signed, as they always were in production:
+-- the app checks seat counts itself and free seats are computed as a (possibly negative) difference
+CREATE TABLE IF NOT EXISTS venue (
+	table_number INT NOT NULL auto_increment,
+	seats INT NOT NULL DEFAULT 6,
+
+	PRIMARY KEY (table_number)
+);
+
+-- time_arrived is set by the app when the guest arrives, not by the implicit mysql ON UPDATE
+CREATE TABLE IF NOT EXISTS guestlist (
+	id INT NOT NULL auto_increment,
+	guest_name VARCHAR (64) CHARACTER SET utf8 UNIQUE,
+	table_number INT NOT NULL,
+	accompanying_guests INT NOT NULL,
+	time_arrived TIMESTAMP NULL DEFAULT NULL,
+	arrived BOOLEAN DEFAULT FALSE,
+
+	PRIMARY KEY (id),
+	FOREIGN KEY (table_number) REFERENCES venue(table_number)
+);
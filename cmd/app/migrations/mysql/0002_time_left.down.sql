@@ -0,0 +1 @@
+ALTER TABLE guestlist DROP COLUMN time_left;
@@ -0,0 +1,2 @@
+DROP INDEX guestlist_guest_name ON guestlist;
+ALTER TABLE guestlist ADD UNIQUE INDEX guest_name (guest_name);
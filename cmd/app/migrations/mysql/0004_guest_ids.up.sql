@@ -0,0 +1,3 @@
+-- Guests are identified by id, names are only searched for and may repeat
+ALTER TABLE guestlist DROP INDEX guest_name;
+CREATE INDEX guestlist_guest_name ON guestlist (guest_name);
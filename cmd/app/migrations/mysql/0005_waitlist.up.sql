@@ -0,0 +1,15 @@
+-- Guests turned away for lack of seats, promoted to the guestlist by priority once seats free up
+-- table_number 0 takes any table, promoted entries keep the table and guest they got
+CREATE TABLE IF NOT EXISTS waitlist (
+	id INT NOT NULL auto_increment,
+	guest_name VARCHAR (64) CHARACTER SET utf8 NOT NULL,
+	table_number INT NOT NULL DEFAULT 0,
+	accompanying_guests INT NOT NULL,
+	priority INT NOT NULL DEFAULT 0,
+	unique_name BOOLEAN NOT NULL DEFAULT FALSE,
+	requested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	promoted_at TIMESTAMP NULL DEFAULT NULL,
+	guest_id INT NULL DEFAULT NULL,
+
+	PRIMARY KEY (id)
+);
@@ -0,0 +1 @@
+-- Nothing to revert, the column is left as 0001_init declares it
@@ -0,0 +1,6 @@
+-- Databases adopted from the old docker dump declared time_arrived as their first TIMESTAMP column, which MySQL 5.7
+-- makes NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP: every update of a guest overwrote it
+ALTER TABLE guestlist MODIFY time_arrived TIMESTAMP NULL DEFAULT NULL;
+
+-- guests yet to arrive got the time they were added
+UPDATE guestlist SET time_arrived = NULL WHERE NOT arrived AND time_left IS NULL;
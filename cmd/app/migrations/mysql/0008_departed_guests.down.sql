@@ -0,0 +1,3 @@
+-- Guests of removed tables can't be kept with the foreign key back
+DELETE FROM guestlist WHERE table_number NOT IN (SELECT table_number FROM venue);
+ALTER TABLE guestlist ADD CONSTRAINT guestlist_ibfk_1 FOREIGN KEY (table_number) REFERENCES venue(table_number);
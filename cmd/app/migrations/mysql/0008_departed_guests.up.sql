@@ -0,0 +1,3 @@
+-- Departed guests keep the number of a table removed after they left, as history: table_number no longer references venue
+-- The foreign key is unnamed in 0001_init and in the old dump, InnoDB named it guestlist_ibfk_1 in both
+ALTER TABLE guestlist DROP FOREIGN KEY guestlist_ibfk_1;
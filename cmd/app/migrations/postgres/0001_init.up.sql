@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS venue (
+	table_number SERIAL PRIMARY KEY,
+	seats INT NOT NULL DEFAULT 6
+);
+
+-- time_arrived keeps second precision, like the mysql TIMESTAMP
+CREATE TABLE IF NOT EXISTS guestlist (
+	id SERIAL PRIMARY KEY,
+	guest_name VARCHAR (64) UNIQUE,
+	table_number INT NOT NULL REFERENCES venue(table_number),
+	accompanying_guests INT NOT NULL,
+	time_arrived TIMESTAMP (0) WITH TIME ZONE,
+	arrived BOOLEAN DEFAULT FALSE
+);
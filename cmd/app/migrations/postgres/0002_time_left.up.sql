@@ -0,0 +1,2 @@
+-- Leaving the venue keeps the guest on the guestlist, time_left set means their seats are free again
+ALTER TABLE guestlist ADD COLUMN time_left TIMESTAMP (0) WITH TIME ZONE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS guest_movements;
+ALTER TABLE guestlist DROP COLUMN present;
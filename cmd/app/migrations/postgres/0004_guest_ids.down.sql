@@ -0,0 +1,2 @@
+DROP INDEX guestlist_guest_name;
+ALTER TABLE guestlist ADD CONSTRAINT guestlist_guest_name_key UNIQUE (guest_name);
@@ -0,0 +1,13 @@
+-- Guests turned away for lack of seats, promoted to the guestlist by priority once seats free up
+-- table_number 0 takes any table, promoted entries keep the table and guest they got
+CREATE TABLE IF NOT EXISTS waitlist (
+	id SERIAL PRIMARY KEY,
+	guest_name VARCHAR (64) NOT NULL,
+	table_number INT NOT NULL DEFAULT 0,
+	accompanying_guests INT NOT NULL,
+	priority INT NOT NULL DEFAULT 0,
+	unique_name BOOLEAN NOT NULL DEFAULT FALSE,
+	requested_at TIMESTAMP (0) WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	promoted_at TIMESTAMP (0) WITH TIME ZONE,
+	guest_id INT
+);
@@ -0,0 +1,2 @@
+-- Departed guests keep the number of a table removed after they left, as history: table_number no longer references venue
+ALTER TABLE guestlist DROP CONSTRAINT guestlist_table_number_fkey;
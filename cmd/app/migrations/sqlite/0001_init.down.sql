@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS guestlist;
+DROP TABLE IF EXISTS venue;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS venue (
+	table_number INTEGER PRIMARY KEY AUTOINCREMENT,
+	seats INT NOT NULL DEFAULT 6
+);
+
+CREATE TABLE IF NOT EXISTS guestlist (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_name VARCHAR (64) UNIQUE,
+	table_number INT NOT NULL,
+	accompanying_guests INT NOT NULL,
+	time_arrived TIMESTAMP,
+	arrived BOOLEAN DEFAULT FALSE,
+
+	FOREIGN KEY (table_number) REFERENCES venue(table_number)
+);
@@ -0,0 +1,15 @@
+-- present is the head-count of the party currently at the venue, guests who already arrived came with their whole entourage
+ALTER TABLE guestlist ADD COLUMN present INT NOT NULL DEFAULT 0;
+UPDATE guestlist SET present = accompanying_guests + 1 WHERE arrived = TRUE;
+
+-- Every arrival, check-in, check-out and departure of a party
+CREATE TABLE IF NOT EXISTS guest_movements (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_id INT NOT NULL,
+	event VARCHAR (16) NOT NULL,
+	people INT NOT NULL,
+	present INT NOT NULL,
+	moved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (guest_id) REFERENCES guestlist(id) ON DELETE CASCADE
+);
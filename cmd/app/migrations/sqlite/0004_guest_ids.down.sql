@@ -0,0 +1,36 @@
+-- Same rebuild as the up migration, with the UNIQUE constraint back
+CREATE TABLE guestlist_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_name VARCHAR (64) UNIQUE,
+	table_number INT NOT NULL,
+	accompanying_guests INT NOT NULL,
+	time_arrived TIMESTAMP,
+	arrived BOOLEAN DEFAULT FALSE,
+	time_left TIMESTAMP,
+	present INT NOT NULL DEFAULT 0,
+
+	FOREIGN KEY (table_number) REFERENCES venue(table_number)
+);
+INSERT INTO guestlist_new (id, guest_name, table_number, accompanying_guests, time_arrived, arrived, time_left, present)
+	SELECT id, guest_name, table_number, accompanying_guests, time_arrived, arrived, time_left, present FROM guestlist;
+-- ids of removed guests are never reused
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'guestlist') WHERE name = 'guestlist_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'guestlist_new', seq FROM sqlite_sequence WHERE name = 'guestlist'
+	AND NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'guestlist_new');
+
+CREATE TABLE guest_movements_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_id INT NOT NULL,
+	event VARCHAR (16) NOT NULL,
+	people INT NOT NULL,
+	present INT NOT NULL,
+	moved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (guest_id) REFERENCES guestlist_new(id) ON DELETE CASCADE
+);
+INSERT INTO guest_movements_new SELECT * FROM guest_movements;
+
+DROP TABLE guest_movements;
+DROP TABLE guestlist;
+ALTER TABLE guestlist_new RENAME TO guestlist;
+ALTER TABLE guest_movements_new RENAME TO guest_movements;
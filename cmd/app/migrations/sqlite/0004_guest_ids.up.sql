@@ -0,0 +1,42 @@
+-- Guests are identified by id, names are only searched for and may repeat
+-- sqlite can't drop the UNIQUE constraint, guestlist is rebuilt without it.
+-- guest_movements is rebuilt too, dropping guestlist would otherwise cascade to it,
+-- renaming guestlist_new afterwards rewrites the new foreign key to guestlist
+CREATE TABLE guestlist_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_name VARCHAR (64),
+	table_number INT NOT NULL,
+	accompanying_guests INT NOT NULL,
+	time_arrived TIMESTAMP,
+	arrived BOOLEAN DEFAULT FALSE,
+	time_left TIMESTAMP,
+	present INT NOT NULL DEFAULT 0,
+
+	FOREIGN KEY (table_number) REFERENCES venue(table_number)
+);
+INSERT INTO guestlist_new (id, guest_name, table_number, accompanying_guests, time_arrived, arrived, time_left, present)
+	SELECT id, guest_name, table_number, accompanying_guests, time_arrived, arrived, time_left, present FROM guestlist;
+
+-- ids of removed guests are never reused
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'guestlist') WHERE name = 'guestlist_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'guestlist_new', seq FROM sqlite_sequence WHERE name = 'guestlist'
+	AND NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'guestlist_new');
+
+CREATE TABLE guest_movements_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guest_id INT NOT NULL,
+	event VARCHAR (16) NOT NULL,
+	people INT NOT NULL,
+	present INT NOT NULL,
+	moved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (guest_id) REFERENCES guestlist_new(id) ON DELETE CASCADE
+);
+INSERT INTO guest_movements_new SELECT * FROM guest_movements;
+
+DROP TABLE guest_movements;
+DROP TABLE guestlist;
+ALTER TABLE guestlist_new RENAME TO guestlist;
+ALTER TABLE guest_movements_new RENAME TO guest_movements;
+
+CREATE INDEX guestlist_guest_name ON guestlist (guest_name);
@@ -0,0 +1,2 @@
+-- time_arrived was always nullable here, only guests yet to arrive are cleared as on mysql
+UPDATE guestlist SET time_arrived = NULL WHERE NOT arrived AND time_left IS NULL;
@@ -0,0 +1,5 @@
+-- A row per name added with a unique check, locked by the transaction checking it
+-- so adding the same name twice waits on this row instead of on every table
+CREATE TABLE IF NOT EXISTS guest_name_locks (
+	guest_name VARCHAR (64) NOT NULL PRIMARY KEY
+);
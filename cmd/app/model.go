@@ -3,19 +3,27 @@
 package main
 
 import (
-	"database/sql"
-	"errors"
-
-	_ "github.com/go-sql-driver/mysql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
 // Base struct to store guest info
 type Guest struct {
+	ID                 int    `json:"id,omitempty"`
 	Name               string `json:"name,omitempty"`
 	Table              int    `json:"table,omitempty"`
 	AccompanyingGuests int    `json:"accompanying_guests"`
 	TimeArrived        string `json:"time_arrived,omitempty"`
 	Arrived            int    `json:"arrived,omitempty"`
+	TimeLeft           string `json:"time_left,omitempty"`
+	Present            int    `json:"present,omitempty"` // head-count of the party at the venue
+}
+
+// Changes to a guest, nil fields are left as they are
+type GuestEdit struct {
+	Name  *string `json:"name"`
+	Table *int    `json:"table"`
 }
 
 // Struct used multiple guest body responses
@@ -23,195 +31,153 @@ type GuestList struct {
 	Guests []Guest `json:"guests"`
 }
 
+// A page of a filtered guest listing
+type GuestPage struct {
+	Guests     []Guest `json:"guests"`
+	Total      int     `json:"total"`                 // guests matching the filters, on every page
+	NextCursor string  `json:"next_cursor,omitempty"` // cursor of the next page, empty on the last one
+}
+
 // Struct used for /seats_empty endpoint body
 type SeatsEmpty struct {
 	Seats int `json:"seats_empty"`
 }
 
-//Adds a new table to the venue table
-func addTable(db *sql.DB, seats int) error {
-	_, err := db.Exec("INSERT INTO venue (seats) values (?)", seats)
-
-	return err
+// A party's arrival, check-in, check-out or departure
+type Movement struct {
+	Event   string `json:"event"`
+	People  int    `json:"people"`  // people who came in or went out
+	Present int    `json:"present"` // head-count of the party at the venue afterwards
+	Time    string `json:"time"`
 }
 
-// Handles the addition of new guests to the guestlist
-func (g *Guest) addGuest(db *sql.DB) error {
-
-	// Checking number of free seats instead of relying on DBs strict mode with UNSIGNED
-	freeSeats, err := getFreeSeats(db, g.Table, false)
-	freeSeats = freeSeats - g.AccompanyingGuests - 1 // main guest is not accounted by AccompanyingGuests
-
-	if err != nil {
-		return err
-	}
-
-	// if there aren't enough sits
-	if freeSeats < 0 {
-		return errors.New("unable to add guest")
-	}
-
-	// Adds guest to guestlist table
-	_, err = db.Exec("INSERT INTO guestlist (guest_name, table_number, accompanying_guests, arrived) values (?, ?, ?, ?)", g.Name, g.Table, g.AccompanyingGuests, false)
-
-	return err
+// Struct used for /guests/name/timeline endpoint body
+type Timeline struct {
+	Name      string     `json:"name"`
+	Movements []Movement `json:"movements"`
 }
 
-// Updates DB entry with time_arrived and sets arrived flag to "true"
-func (g *Guest) updateGuest(db *sql.DB) error {
-
-	// Get previous ammount of accompanying guests
-	var previousAccompanyingGuests int
-	err := db.QueryRow("SELECT table_number, accompanying_guests FROM guestlist WHERE guest_name = ?", g.Name).Scan(&g.Table, &previousAccompanyingGuests)
-
-	if err != nil {
-		return err
-	}
-
-	// if there are no changes in accompanying guests doesn't check sits
-	// else checks sits
-	if previousAccompanyingGuests == g.AccompanyingGuests {
-
-		// updates guest on DB
-		_, err = db.Exec("UPDATE guestlist SET time_arrived=NOW(), arrived=? WHERE guest_name=?", true, g.Name)
-
-		return err
-
-	} else {
-		// Checking number of free seats
-		var freeSeats int
-		freeSeats, err = getFreeSeats(db, g.Table, false)
-
-		freeSeats = freeSeats + previousAccompanyingGuests - g.AccompanyingGuests // new free seats count
-
-		if err != nil {
-			return err
-		}
-
-		// if there aren't enough sits
-		if freeSeats < 0 {
-			return errors.New("unable to add guest")
-		}
-
-		// updates guest on DB
-		_, err = db.Exec("UPDATE guestlist SET accompanying_guests=?, time_arrived=NOW(), arrived=? WHERE guest_name=?", g.AccompanyingGuests, true, g.Name)
-		return err
-	}
-
+// A guest waiting for seats, added to the guestlist by priority once they free up
+type WaitlistEntry struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	Table              int    `json:"table"` // table asked for (0 for any), the one given once promoted
+	AccompanyingGuests int    `json:"accompanying_guests"`
+	Priority           int    `json:"priority"` // higher goes first
+	Unique             bool   `json:"-"`        // the name can't be on the guestlist, as on POST /guest_list/name
+	RequestedAt        string `json:"requested_at"`
+	PromotedAt         string `json:"promoted_at,omitempty"`
+	GuestID            int    `json:"guest_id,omitempty"` // guest added by the promotion
 }
 
-// Queries databse and returns a GuestList struct with all guests on the guestlist table
-func getGuestList(db *sql.DB) (GuestList, error) {
-	gl := GuestList{}
-	gl.Guests = []Guest{}
-
-	// Get all guests from guestlist
-	rows, err := db.Query("SELECT guest_name, table_number, accompanying_guests FROM guestlist")
-
-	if err != nil {
-		return gl, err
-	}
-
-	defer rows.Close()
-
-	// Foreach guest
-	for rows.Next() {
-		var g Guest
-
-		if err := rows.Scan(&g.Name, &g.Table, &g.AccompanyingGuests); err != nil {
-			return gl, err
-		}
-
-		gl.Guests = append(gl.Guests, g)
-	}
-
-	return gl, nil // append guest to GuestList
+// Struct used for /waitlist endpoint body
+type Waitlist struct {
+	Entries []WaitlistEntry `json:"waitlist"`
 }
 
-// Queries databse and returns a GuestList struct with arrived guests
-func getArrivedGuests(db *sql.DB) (GuestList, error) {
-	gl := GuestList{}
-	gl.Guests = []Guest{}
-
-	// Get all guests with arrived=true from guestlist
-	rows, err := db.Query("SELECT guest_name, table_number, time_arrived FROM guestlist WHERE arrived=1")
-
-	if err != nil {
-		return gl, err
-	}
-
-	defer rows.Close()
-
-	// Foreach guest
-	for rows.Next() {
-		var g Guest
-
-		if err := rows.Scan(&g.Name, &g.Table, &g.TimeArrived); err != nil {
-			return gl, err
-		}
+// Venue table info
+type Table struct {
+	Number     int `json:"table_number"`
+	Seats      int `json:"seats"`
+	SeatsEmpty int `json:"seats_empty"`
+}
 
-		gl.Guests = append(gl.Guests, g) // append guest to GuestList
-	}
+// Struct used for /venue endpoint body
+type Venue struct {
+	Tables []Table `json:"tables"`
+}
 
-	return gl, nil
+// Problem details (RFC 7807) answered for every failed request, as application/problem+json
+type Problem struct {
+	Type   string `json:"type"`  // always about:blank, problems are told apart by code
+	Title  string `json:"title"` // text of the status
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Code   string `json:"code"`            // the Code of the Error
+	Param  string `json:"param,omitempty"` // request field or parameter at fault
 }
 
-// Deletes guest entry from DB
-func deleteGuest(db *sql.DB, name string) error {
+// Kinds of failure, each answered with its own HTTP status
+type ErrorKind int
 
-	_, err := db.Exec("DELETE FROM guestlist WHERE guest_name = ?", name)
+const (
+	ErrorInvalid  ErrorKind = iota + 1 // the request itself is wrong, like a missing name
+	ErrorNotFound                      // a guest, table or waitlist entry that doesn't exist
+	ErrorConflict                      // the request can't be done as things stand, like a full table
+)
 
-	return err
+// Failure of a guest list operation, with a code clients can tell it by
+type Error struct {
+	Kind    ErrorKind
+	Code    string // machine-readable and stable, like guest_not_found
+	Message string
+	Param   string // request field or parameter at fault, for ErrorInvalid
 }
 
-/* Queries database for the number of free seats
-	If all = false, returns amount of free seats on table
- 	If all = true, returns all available seats
-*/
-func getFreeSeats(db *sql.DB, table int, all bool) (int, error) {
-
-	var freeSeats int
-	var usedSeats int
-	var err error
+func (e *Error) Error() string {
+	return e.Message
+}
 
-	if all { // Get free seats
+// Errors shared by every Store implementation
+var (
+	ErrTableFull      = &Error{Kind: ErrorConflict, Code: "table_full", Message: "unable to add guest"}
+	ErrTableNotFound  = &Error{Kind: ErrorNotFound, Code: "table_not_found", Message: "table not found"}
+	ErrGuestNotFound  = &Error{Kind: ErrorNotFound, Code: "guest_not_found", Message: "guest not found"}
+	ErrDuplicateGuest = &Error{Kind: ErrorConflict, Code: "duplicate_guest", Message: "guest already on the guestlist"}
+	ErrGuestNotHere   = &Error{Kind: ErrorConflict, Code: "guest_not_here", Message: "guest is not at the venue"}
+	ErrPartyTooSmall  = &Error{Kind: ErrorConflict, Code: "party_too_small", Message: "fewer people present than checking out"}
+	ErrAmbiguousGuest = &Error{Kind: ErrorConflict, Code: "ambiguous_guest", Message: "more than one guest with that name, use their id"}
+	ErrEntryNotFound  = &Error{Kind: ErrorNotFound, Code: "waitlist_entry_not_found", Message: "waitlist entry not found"}
+
+	// parties that can't take more seats where they are, or get seats somewhere else
+	ErrPartyTooBig      = &Error{Kind: ErrorConflict, Code: "party_too_big", Message: "not enough free seats at the table for the bigger party"}
+	ErrNoRoomToMove     = &Error{Kind: ErrorConflict, Code: "no_room_to_move", Message: "not enough free seats at the table the guests are moving to"}
+	ErrNoRoomToReassign = &Error{Kind: ErrorConflict, Code: "no_room_to_reassign", Message: "no table can take the reassigned guests"}
+
+	ErrTableOccupied       = &Error{Kind: ErrorConflict, Code: "table_occupied", Message: "table has seated guests"}
+	ErrSeatsBelowOccupancy = &Error{Kind: ErrorConflict, Code: "seats_below_occupancy", Message: "seats below current occupancy"}
+
+	// a guest or table referred to is gone, or one still referred to was removed, caught by the database
+	ErrBrokenReference = &Error{Kind: ErrorConflict, Code: "broken_reference", Message: "the guest or table involved doesn't exist or is still in use"}
+)
 
-		// query DB for available sits
-		err = db.QueryRow("SELECT SUM(seats) FROM venue").Scan(&freeSeats)
-		if err != nil {
-			return 0, err
-		}
+// Invalid value of request field or parameter (param)
+func invalidParam(param string, message string) *Error {
+	return &Error{Kind: ErrorInvalid, Code: "invalid_parameter", Message: message, Param: param}
+}
 
-		// query DB for used sits
-		err = db.QueryRow("SELECT SUM(accompanying_guests + 1) FROM guestlist").Scan(&usedSeats)
+// Longest guest name, the guest_name columns are VARCHAR (64)
+const maxNameLength = 64
 
-	} else { // Get free seats from specified table number
+// Checks a guest name sent by a client, on adds and renames
+func validName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return invalidParam("name", "name is required")
+	case utf8.RuneCountInString(name) > maxNameLength:
+		return invalidParam("name", fmt.Sprintf("name can't be longer than %d characters", maxNameLength))
+	}
 
-		// query DB for available on specified table
-		err = db.QueryRow("SELECT seats FROM venue WHERE table_number=?", table).Scan(&freeSeats)
-		if err != nil {
-			return 0, err
-		}
+	return nil
+}
 
-		// query DB for used sits on specified table
-		err = db.QueryRow("SELECT SUM(accompanying_guests + 1) FROM guestlist WHERE table_number=?", table).Scan(&usedSeats)
+// Checks the fields of a guest sent by a client (on v1, v2 and imports), seats are checked by the store
+func validGuest(g Guest) error {
+	if err := validName(g.Name); err != nil {
+		return err
 	}
 
-	//TODO properly handle error when there are no guests on guestlist for specified table
-	//BUG workaround setting err nil
-	if err != nil {
-		return freeSeats, nil
+	switch {
+	case g.Table < 0:
+		return invalidParam("table", "table must be a positive number")
+	case g.AccompanyingGuests < 0:
+		return invalidParam("accompanying_guests", "accompanying_guests can't be negative")
 	}
 
-	return freeSeats - usedSeats, err
+	return nil
 }
 
-// Get guest (name) from guestlist
-func getGuest(db *sql.DB, name string) (Guest, error) {
-	var g Guest
-	g.Name = name
-
-	err := db.QueryRow("SELECT table_number, accompanying_guests, arrived FROM guestlist WHERE guest_name=?", g.Name).Scan(&g.Table, &g.AccompanyingGuests, &g.Arrived)
-
-	return g, err
+// Request body that can't be read, err says why
+func invalidPayload(err error) *Error {
+	return &Error{Kind: ErrorInvalid, Code: "invalid_payload", Message: fmt.Sprintf("Invalid request payload: %v", err)}
 }
@@ -0,0 +1,132 @@
+// move.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Moves guest (id) to table, or swaps them with guest (swapWith), exactly one of them is set
+func (a *App) moveGuest(w http.ResponseWriter, id int, table int, swapWith int) {
+
+	if (table > 0) == (swapWith > 0) {
+		respondWithProblem(w, invalidParam("table", "either table or swap_with is required"))
+		return
+	}
+
+	var guests []Guest
+	var err error
+
+	if table > 0 {
+		var g Guest
+		if g, err = a.Store.EditGuest(id, GuestEdit{Table: &table}); err == nil {
+			guests = []Guest{g}
+		}
+	} else {
+		guests, err = a.Store.SwapGuests(id, swapWith)
+	}
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.promoteWaitlist()
+
+	respondWithJSON(w, http.StatusOK, GuestList{Guests: guests})
+}
+
+/*
+### Move a guest
+
+Reseats the guest and their accompanying guests, keeping their arrival.
+With table, the table needs free seats for the whole party.
+With swap_with, the two parties trade tables, each table needs room for the party coming in once the other has gone.
+Throws http.StatusConflict if they don't fit and http.StatusNotFound for unknown guests or tables.
+
+POST /guests/name/move
+body:
+{
+	"table": int,
+	"swap_with": "string"
+}
+response:
+{
+	"guests": [
+		{
+			"id": int,
+			"name": "string",
+			"table": int,
+			...
+		}, ...
+	]
+}
+*/
+func (a *App) handlerMoveGuest(w http.ResponseWriter, r *http.Request) {
+
+	name := mux.Vars(r)["name"] // Get guest name
+
+	body := struct {
+		Table    int    `json:"table"`
+		SwapWith string `json:"swap_with"`
+	}{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	// names are resolved to ids first, shared names are refused
+	var other Guest
+	g, err := a.Store.GetGuest(name)
+	if err == nil && body.SwapWith != "" {
+		other, err = a.Store.GetGuest(body.SwapWith)
+	}
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.moveGuest(w, g.ID, body.Table, other.ID)
+}
+
+/*
+### Move a guest by id
+
+Same as POST /guests/name/move, with ids.
+
+POST /v2/guests/id/move
+body:
+{
+	"table": int,
+	"swap_with": int
+}
+*/
+func (a *App) handlerMoveGuestByID(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := guestID(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("id", "invalid guest id"))
+		return
+	}
+
+	body := struct {
+		Table    int `json:"table"`
+		SwapWith int `json:"swap_with"`
+	}{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	a.moveGuest(w, id, body.Table, body.SwapWith)
+}
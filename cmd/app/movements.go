@@ -0,0 +1,189 @@
+// movements.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Events on a guest's timeline
+const (
+	eventArrived  = "arrived"   // PUT /guests/name, the whole party
+	eventCheckIn  = "check_in"  // some people of the party came in
+	eventCheckOut = "check_out" // some people of the party went out
+	eventLeft     = "left"      // DELETE /guests/name, whoever was still in
+)
+
+// Guest (g) after people of their party check in, given the free seats at their table
+// A party that grows past its reservation takes more seats, refused with ErrPartyTooBig if there aren't enough
+func checkIn(g Guest, people int, free int) (Guest, error) {
+	reserved := partySize(g) // seats held by the party, none if they had left
+	present := g.Present + people
+
+	party := g.AccompanyingGuests + 1 // main guest is not accounted by AccompanyingGuests
+	if present > party {
+		party = present // companions that weren't on the guestlist
+	}
+
+	if party-reserved > free {
+		return g, ErrPartyTooBig
+	}
+
+	g.AccompanyingGuests = party - 1
+	g.Present = present
+	g.Arrived = 1
+	g.TimeLeft = ""
+
+	return g, nil
+}
+
+// Guest (g) after people of their party check out, the seats stay reserved for them
+func checkOut(g Guest, people int) (Guest, error) {
+	if g.Arrived != 1 {
+		return g, ErrGuestNotHere
+	}
+
+	if people > g.Present {
+		return g, ErrPartyTooSmall
+	}
+
+	g.Present -= people
+
+	return g, nil
+}
+
+// Decodes the {"people": int} body of check-ins and check-outs
+func decodePeople(r *http.Request) (int, bool) {
+	body := struct {
+		People int `json:"people"`
+	}{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil || body.People <= 0 {
+		return 0, false
+	}
+	defer r.Body.Close()
+
+	return body.People, true
+}
+
+/*
+### Check-in
+
+Some people of a party come in: the main guest or companions arriving later.
+If more people come in than the party was invited with, the table must have seats for the extras (http.StatusConflict otherwise).
+A guest that hasn't arrived yet (or had left) arrives with them.
+
+POST /guests/name/check_in
+body:
+{
+	"people": int
+}
+response:
+{
+	"name": "string",
+	"table": int,
+	"accompanying_guests": int,
+	"arrived": 1,
+	"present": int
+}
+*/
+func (a *App) handlerCheckIn(w http.ResponseWriter, r *http.Request) {
+
+	name := mux.Vars(r)["name"] // Get guest name
+
+	people, ok := decodePeople(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("people", "people must be a positive number"))
+		return
+	}
+
+	g, err := a.Store.CheckIn(name, people)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, g)
+}
+
+/*
+### Check-out
+
+Some people of a party go out, their seats stay reserved.
+The guest leaves (as on DELETE /guests/name) when the last of them goes out.
+Throws http.StatusConflict if the guest isn't at the venue or fewer people are present.
+
+POST /guests/name/check_out
+body:
+{
+	"people": int
+}
+response:
+{
+	"name": "string",
+	"table": int,
+	"accompanying_guests": int,
+	"arrived": int,
+	"time_left": "string",
+	"present": int
+}
+*/
+func (a *App) handlerCheckOut(w http.ResponseWriter, r *http.Request) {
+
+	name := mux.Vars(r)["name"] // Get guest name
+
+	people, ok := decodePeople(r)
+	if !ok {
+		respondWithProblem(w, invalidParam("people", "people must be a positive number"))
+		return
+	}
+
+	g, err := a.Store.CheckOut(name, people)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	// the last of the party went out, their seats are free
+	if g.TimeLeft != "" {
+		a.promoteWaitlist()
+	}
+
+	respondWithJSON(w, http.StatusOK, g)
+}
+
+/*
+### Guest timeline
+
+Arrivals, check-ins, check-outs and departures of the guest's party, oldest first.
+
+GET /guests/name/timeline
+response:
+{
+	"name": "string",
+	"movements": [
+		{
+			"event": "arrived|check_in|check_out|left",
+			"people": int,
+			"present": int,
+			"time": "string"
+		}, ...
+	]
+}
+*/
+func (a *App) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+
+	name := mux.Vars(r)["name"] // Get guest name
+
+	movements, err := a.Store.GetMovements(name)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Timeline{Name: name, Movements: movements})
+}
@@ -0,0 +1,148 @@
+// occupancy.go
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Guest as listed on a table's occupancy
+type SeatedGuest struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	AccompanyingGuests int    `json:"accompanying_guests"`
+	Arrived            bool   `json:"arrived"`
+	Present            int    `json:"present"` // head-count of the party at the venue
+	TimeArrived        string `json:"time_arrived,omitempty"`
+	TimeLeft           string `json:"time_left,omitempty"`
+}
+
+// Seat counts, by reservation and by who is actually in the room
+type SeatCounts struct {
+	Capacity          int `json:"capacity"`
+	Reserved          int `json:"reserved_seats"`      // guests and their entourage, same accounting as getFreeSeats
+	Arrived           int `json:"arrived"`             // head-count of arrived guests and their entourage at the venue
+	SeatsEmpty        int `json:"seats_empty"`         // capacity - reserved
+	SeatsEmptyPresent int `json:"seats_empty_present"` // capacity - arrived
+}
+
+// Adds the party of guest (g) to the counts, nothing if they left
+func (c *SeatCounts) add(g Guest) {
+	party := partySize(g)
+
+	c.Reserved += party
+	c.SeatsEmpty -= party
+	if g.Arrived == 1 {
+		c.Arrived += g.Present // companions may have checked in or out since
+		c.SeatsEmptyPresent -= g.Present
+	}
+}
+
+// Seats of a venue table and who is sitting there
+type TableOccupancy struct {
+	Number int `json:"table_number"`
+	SeatCounts
+	Guests []SeatedGuest `json:"guests"`
+}
+
+// Struct used for /venue/occupancy endpoint body
+type Occupancy struct {
+	Venue  SeatCounts       `json:"venue"` // totals of the listed tables
+	Tables []TableOccupancy `json:"tables"`
+}
+
+// Groups guests by table and adds up the totals
+func buildOccupancy(tables []Table, guests []Guest) Occupancy {
+	o := Occupancy{Tables: []TableOccupancy{}}
+	index := map[int]int{} // table_number -> position on o.Tables
+
+	for _, t := range tables {
+		counts := SeatCounts{Capacity: t.Seats, SeatsEmpty: t.Seats, SeatsEmptyPresent: t.Seats}
+
+		index[t.Number] = len(o.Tables)
+		o.Tables = append(o.Tables, TableOccupancy{Number: t.Number, SeatCounts: counts, Guests: []SeatedGuest{}})
+
+		o.Venue.Capacity += t.Seats
+		o.Venue.SeatsEmpty += t.Seats
+		o.Venue.SeatsEmptyPresent += t.Seats
+	}
+
+	for _, g := range guests {
+		i, ok := index[g.Table]
+		if !ok {
+			continue
+		}
+
+		t := &o.Tables[i]
+		t.add(g)
+		o.Venue.add(g)
+
+		t.Guests = append(t.Guests, SeatedGuest{ID: g.ID, Name: g.Name, AccompanyingGuests: g.AccompanyingGuests, Arrived: g.Arrived == 1, Present: g.Present, TimeArrived: g.TimeArrived, TimeLeft: g.TimeLeft})
+	}
+
+	return o
+}
+
+/*
+### Table occupancy
+
+Every table (or just ?table_number=int) with its seats and guests.
+Seats are counted both as reserved (the guest list) and as present (arrived guests and their entourage),
+venue holds the totals of the listed tables.
+Throws http.StatusNotFound if there's no such table.
+
+GET /venue/occupancy?table_number=int
+response:
+{
+	"venue": {
+		"capacity": int,
+		"reserved_seats": int,
+		"arrived": int,
+		"seats_empty": int,
+		"seats_empty_present": int
+	},
+	"tables": [
+		{
+			"table_number": int,
+			"capacity": int,
+			"reserved_seats": int,
+			"arrived": int,
+			"seats_empty": int,
+			"seats_empty_present": int,
+			"guests": [
+				{
+					"id": int,
+					"name": "string",
+					"accompanying_guests": int,
+					"arrived": bool,
+					"present": int,
+					"time_arrived": "string",
+					"time_left": "string"
+				}, ...
+			]
+		}, ...
+	]
+}
+*/
+func (a *App) handlerOccupancy(w http.ResponseWriter, r *http.Request) {
+
+	var table int
+	var err error
+
+	if param := r.URL.Query().Get("table_number"); param != "" {
+		if table, err = strconv.Atoi(param); err != nil || table <= 0 {
+			respondWithProblem(w, invalidParam("table_number", "table_number must be a positive number"))
+			return
+		}
+	}
+
+	tables, guests, err := a.Store.GetSeating(table)
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, buildOccupancy(tables, guests))
+}
@@ -0,0 +1,175 @@
+// pagination.go
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Page sizes of the guest listings
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Orders the guest listings can be sorted by, every one ends with the id so it's total
+var guestSorts = map[string]bool{"id": true, "name": true, "table": true, "time_arrived": true}
+
+// Filters, order and page of a guest listing, zero values don't filter
+type GuestQuery struct {
+	Table        int
+	Arrived      *bool
+	NamePrefix   string
+	MinParty     int // guest and accompanying guests
+	MaxParty     int
+	ArrivedAfter time.Time
+	Sort         string       // id (the order guests were added), name, table or time_arrived (guests yet to arrive last)
+	After        *GuestCursor // page starts after this guest, nil for the first page
+	Limit        int
+}
+
+// Position of a guest on a sorted listing: the sort key and the id
+// Sent to clients as base64url JSON, pages stay stable while guests are added or removed
+type GuestCursor struct {
+	Sort string `json:"sort"`
+	Key  string `json:"key"` // name, table number or time_arrived (empty if they haven't arrived) of the guest, empty when sorted by id
+	ID   int    `json:"id"`
+}
+
+// Cursor of guest (g) on listings sorted by sort
+func cursorOf(g Guest, sort string) GuestCursor {
+	c := GuestCursor{Sort: sort, ID: g.ID}
+
+	switch sort {
+	case "name":
+		c.Key = g.Name
+	case "table":
+		c.Key = strconv.Itoa(g.Table)
+	case "time_arrived":
+		c.Key = g.TimeArrived
+	}
+
+	return c
+}
+
+// Opaque form of cursor (c)
+func (c GuestCursor) encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Reads a cursor sent by a client for listings sorted by sort
+func decodeCursor(s string, sort string) (*GuestCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalidParam("cursor", "invalid cursor")
+	}
+
+	var c GuestCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, invalidParam("cursor", "invalid cursor")
+	}
+
+	if c.Sort != sort {
+		return nil, invalidParam("cursor", "cursor is for another sort, keep the sort of the first page")
+	}
+
+	if _, err := strconv.Atoi(c.Key); sort == "table" && err != nil {
+		return nil, invalidParam("cursor", "invalid cursor")
+	}
+	if _, err := time.Parse(time.RFC3339, c.Key); sort == "time_arrived" && c.Key != "" && err != nil {
+		return nil, invalidParam("cursor", "invalid cursor")
+	}
+
+	return &c, nil
+}
+
+// Listing parameters, pagination is only used if one of them is given
+var guestQueryParams = []string{"table", "arrived", "name_prefix", "min_party", "max_party", "arrived_after", "sort", "limit", "cursor"}
+
+// Guest query from the request parameters, paged = false if none was given (the whole list, as it always was)
+func requestGuestQuery(r *http.Request) (q GuestQuery, paged bool, err error) {
+	params := r.URL.Query()
+
+	for _, name := range guestQueryParams {
+		paged = paged || params.Get(name) != ""
+	}
+
+	q = GuestQuery{Sort: "id", Limit: defaultPageSize, NamePrefix: params.Get("name_prefix")}
+
+	// positive number in param, 0 if not given
+	number := func(param string) (int, error) {
+		value := params.Get(param)
+		if value == "" {
+			return 0, nil
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, invalidParam(param, param+" must be a positive number")
+		}
+		return n, nil
+	}
+
+	if q.Table, err = number("table"); err != nil {
+		return q, paged, err
+	}
+	if q.MinParty, err = number("min_party"); err != nil {
+		return q, paged, err
+	}
+	if q.MaxParty, err = number("max_party"); err != nil {
+		return q, paged, err
+	}
+
+	if limit, err := number("limit"); err != nil || limit > maxPageSize {
+		return q, paged, invalidParam("limit", "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+	} else if limit > 0 {
+		q.Limit = limit
+	}
+
+	switch params.Get("arrived") {
+	case "":
+	case "true", "false":
+		arrived := params.Get("arrived") == "true"
+		q.Arrived = &arrived
+	default:
+		return q, paged, invalidParam("arrived", "arrived must be true or false")
+	}
+
+	if after := params.Get("arrived_after"); after != "" {
+		if q.ArrivedAfter, err = time.Parse(time.RFC3339, after); err != nil {
+			return q, paged, invalidParam("arrived_after", "arrived_after must be an RFC 3339 time, like 2021-06-01T18:00:00Z")
+		}
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		if !guestSorts[sort] {
+			return q, paged, invalidParam("sort", "sort must be id, name, table or time_arrived")
+		}
+		q.Sort = sort
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" {
+		if q.After, err = decodeCursor(cursor, q.Sort); err != nil {
+			return q, paged, err
+		}
+	}
+
+	return q, paged, nil
+}
+
+// Answers a paged listing of guests for query (q)
+func (a *App) respondWithGuestPage(w http.ResponseWriter, q GuestQuery) {
+	page, err := a.Store.QueryGuests(q)
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
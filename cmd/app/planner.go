@@ -0,0 +1,566 @@
+// planner.go
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// A seating rule for the planner
+type Constraint struct {
+	Type   string   `json:"type"`             // together, apart or pinned
+	Guests []string `json:"guests,omitempty"` // guest names
+	IDs    []int    `json:"ids,omitempty"`    // or ids, for names shared by several guests
+	Tables []int    `json:"tables,omitempty"` // tables the guests are pinned to
+}
+
+// Struct used for /venue/plan endpoint body
+type PlanRequest struct {
+	Constraints []Constraint `json:"constraints"`
+}
+
+// A guest the plan seats at another table
+type PlannedMove struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// A constraint the plan doesn't meet
+type UnsatisfiedConstraint struct {
+	Constraint int    `json:"constraint"` // position on the request's constraints
+	Type       string `json:"type"`
+	Reason     string `json:"reason"`
+}
+
+// Struct used for /venue/plan endpoint response
+type SeatingPlan struct {
+	Applied     bool                    `json:"applied"`
+	Moves       []PlannedMove           `json:"moves"`
+	Unsatisfied []UnsatisfiedConstraint `json:"unsatisfied"`
+	Unplaced    []Guest                 `json:"unplaced"` // parties that fit on no table, such a plan can't be applied
+	Tables      []Table                 `json:"tables"`   // seats once the plan is applied
+}
+
+// Guest id -> table of every planned move
+func (plan SeatingPlan) moveMap() map[int]int {
+	moves := map[int]int{}
+	for _, m := range plan.Moves {
+		moves[m.ID] = m.To
+	}
+
+	return moves
+}
+
+// Seating plan under construction
+type planner struct {
+	tables  []Table
+	guests  []Guest
+	index   map[int]int          // guest id -> position on guests
+	seat    map[int]int          // guest id -> planned table
+	placed  map[int]bool         // guests whose table is decided, arrived guests are never moved
+	free    map[int]int          // table_number -> free seats with the placed guests
+	pins    map[int]map[int]bool // guest id -> tables allowed by pinned constraints
+	apart   map[int][]int        // guest id -> guests that mustn't share their table
+	parent  map[int]int          // union-find of guests that sit together
+	plan    SeatingPlan
+	touched map[int]bool // guests named by a constraint
+}
+
+// Plans the seating of guests who haven't arrived over tables, trying to meet constraints
+// Guests already at the venue stay where they are. Parties are placed most constrained first,
+// keeping their table when it still works, otherwise on the table they fill the most.
+// Constraints that can't all be met are relaxed in order: apart, then pinned, then together (splitting the group)
+func planSeating(tables []Table, guests []Guest, constraints []Constraint) SeatingPlan {
+	p := planner{
+		tables:  tables,
+		guests:  guests,
+		index:   map[int]int{},
+		seat:    map[int]int{},
+		placed:  map[int]bool{},
+		free:    map[int]int{},
+		pins:    map[int]map[int]bool{},
+		apart:   map[int][]int{},
+		parent:  map[int]int{},
+		touched: map[int]bool{},
+		plan:    SeatingPlan{Moves: []PlannedMove{}, Unsatisfied: []UnsatisfiedConstraint{}, Unplaced: []Guest{}, Tables: []Table{}},
+	}
+
+	for _, t := range tables {
+		p.free[t.Number] = t.Seats
+	}
+
+	for i, g := range guests {
+		p.index[g.ID] = i
+		p.seat[g.ID] = g.Table
+		p.parent[g.ID] = g.ID
+
+		// arrived guests keep their seats, departed ones don't take any
+		if g.Arrived == 1 || g.TimeLeft != "" {
+			p.placed[g.ID] = true
+			p.free[g.Table] -= partySize(g)
+		}
+	}
+
+	resolved := make([][]int, len(constraints))
+	reasons := make([]string, len(constraints))
+
+	for i, c := range constraints {
+		ids, reason := p.resolve(c)
+		resolved[i], reasons[i] = ids, reason
+		if reason != "" {
+			continue
+		}
+
+		for _, id := range ids {
+			p.touched[id] = true
+		}
+
+		switch c.Type {
+		case "together":
+			for _, id := range ids[1:] {
+				p.union(ids[0], id)
+			}
+		case "apart":
+			for _, id := range ids {
+				for _, other := range ids {
+					if other != id {
+						p.apart[id] = append(p.apart[id], other)
+					}
+				}
+			}
+		case "pinned":
+			for _, id := range ids {
+				allowed := map[int]bool{}
+				for _, table := range c.Tables {
+					if previous, ok := p.pins[id]; !ok || previous[table] {
+						allowed[table] = true
+					}
+				}
+				p.pins[id] = allowed
+			}
+		}
+	}
+
+	for _, group := range p.groups() {
+		p.placeGroup(group)
+	}
+
+	for i, c := range constraints {
+		if reasons[i] == "" {
+			reasons[i] = p.check(c, resolved[i])
+		}
+		if reasons[i] != "" {
+			p.plan.Unsatisfied = append(p.plan.Unsatisfied, UnsatisfiedConstraint{Constraint: i, Type: c.Type, Reason: reasons[i]})
+		}
+	}
+
+	for _, g := range guests {
+		if p.seat[g.ID] != g.Table {
+			p.plan.Moves = append(p.plan.Moves, PlannedMove{ID: g.ID, Name: g.Name, From: g.Table, To: p.seat[g.ID]})
+		}
+	}
+
+	for _, t := range tables {
+		p.plan.Tables = append(p.plan.Tables, Table{Number: t.Number, Seats: t.Seats, SeatsEmpty: p.free[t.Number]})
+	}
+
+	return p.plan
+}
+
+// Ids of the guests named by constraint (c), or why they can't be told apart
+func (p *planner) resolve(c Constraint) ([]int, string) {
+	ids := []int{}
+
+	for _, name := range c.Guests {
+		found := 0
+		for _, g := range p.guests {
+			if g.Name == name {
+				ids = append(ids, g.ID)
+				found++
+			}
+		}
+
+		if found == 0 {
+			return nil, fmt.Sprintf("unknown guest %q", name)
+		}
+		if found > 1 {
+			return nil, fmt.Sprintf("more than one guest called %q, use their ids", name)
+		}
+	}
+
+	for _, id := range c.IDs {
+		if _, ok := p.index[id]; !ok {
+			return nil, "unknown guest id " + strconv.Itoa(id)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, ""
+}
+
+// Why the planned seating doesn't meet constraint (c) on guests (ids), empty if it does
+func (p *planner) check(c Constraint, ids []int) string {
+	tables := map[int]bool{}
+	for _, id := range ids {
+		tables[p.seat[id]] = true
+	}
+
+	switch c.Type {
+	case "together":
+		if len(tables) > 1 {
+			return "the guests don't fit on one table"
+		}
+	case "apart":
+		if len(tables) < len(ids) {
+			return "some of the guests share a table"
+		}
+	case "pinned":
+		for _, id := range ids {
+			if !p.pins[id][p.seat[id]] {
+				return "some of the guests are seated elsewhere"
+			}
+		}
+	}
+
+	return ""
+}
+
+// Root of guest (id) on the together union-find
+func (p *planner) find(id int) int {
+	for p.parent[id] != id {
+		p.parent[id] = p.parent[p.parent[id]]
+		id = p.parent[id]
+	}
+
+	return id
+}
+
+// Seats guests (a) and (b) together
+func (p *planner) union(a int, b int) {
+	p.parent[p.find(a)] = p.find(b)
+}
+
+// Guests to seat together, most constrained first
+func (p *planner) groups() [][]int {
+	members := map[int][]int{}
+	roots := []int{}
+
+	for _, g := range p.guests {
+		root := p.find(g.ID)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], g.ID)
+	}
+
+	groups := [][]int{}
+	for _, root := range roots {
+		groups = append(groups, members[root])
+	}
+
+	// anchored to an arrived guest, then by how few tables they may use, then the biggest
+	rank := func(group []int) (bool, int, int) {
+		allowed := len(p.tables) + 1
+		if a := p.allowed(group); a != nil {
+			allowed = len(a)
+		}
+
+		return p.anchor(group) == 0, allowed, -p.size(group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		ai, bi, ci := rank(groups[i])
+		aj, bj, cj := rank(groups[j])
+
+		if ai != aj {
+			return !ai
+		}
+		if bi != bj {
+			return bi < bj
+		}
+		return ci < cj
+	})
+
+	return groups
+}
+
+// Table of the first arrived guest of group, 0 if nobody has arrived
+func (p *planner) anchor(group []int) int {
+	for _, id := range group {
+		if g := p.guests[p.index[id]]; g.Arrived == 1 {
+			return g.Table
+		}
+	}
+
+	return 0
+}
+
+// Seats needed by the guests of group still to be placed
+func (p *planner) size(group []int) int {
+	size := 0
+	for _, id := range group {
+		if !p.placed[id] {
+			size += partySize(p.guests[p.index[id]])
+		}
+	}
+
+	return size
+}
+
+// Tables every pinned guest of group may use, nil if none is pinned
+func (p *planner) allowed(group []int) map[int]bool {
+	var allowed map[int]bool
+
+	for _, id := range group {
+		pins, ok := p.pins[id]
+		if !ok || p.placed[id] {
+			continue
+		}
+
+		if allowed == nil {
+			allowed = pins
+			continue
+		}
+
+		both := map[int]bool{}
+		for table := range pins {
+			if allowed[table] {
+				both[table] = true
+			}
+		}
+		allowed = both
+	}
+
+	return allowed
+}
+
+// Whether seating group at table puts someone next to a guest they must be apart from
+func (p *planner) clashes(group []int, table int) bool {
+	for _, id := range group {
+		for _, other := range p.apart[id] {
+			if p.placed[other] && p.seat[other] == table {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Seats the guests of group still to be placed on one table, relaxing constraints as needed
+// If no table has room for all of them, they are placed one by one
+func (p *planner) placeGroup(group []int) {
+	pending := []int{}
+	for _, id := range group {
+		if !p.placed[id] {
+			pending = append(pending, id)
+		}
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	size := p.size(pending)
+	anchor := p.anchor(group)
+	allowed := p.allowed(pending)
+
+	// every rule, then without apart, then only the anchor, then anywhere
+	levels := []func(table int) bool{
+		func(table int) bool {
+			return (anchor == 0 || table == anchor) && (allowed == nil || allowed[table]) && !p.clashes(pending, table)
+		},
+		func(table int) bool { return (anchor == 0 || table == anchor) && (allowed == nil || allowed[table]) },
+		func(table int) bool { return anchor == 0 || table == anchor },
+		func(table int) bool { return true },
+	}
+
+	for _, ok := range levels {
+		if table := p.pick(pending, size, ok); table != 0 {
+			for _, id := range pending {
+				p.seat[id] = table
+				p.placed[id] = true
+			}
+			p.free[table] -= size
+			return
+		}
+	}
+
+	if len(pending) > 1 {
+		for _, id := range pending {
+			p.placeGroup([]int{id})
+		}
+		return
+	}
+
+	// fits nowhere, stays on their table and over its capacity
+	g := p.guests[p.index[pending[0]]]
+	p.placed[g.ID] = true
+	p.free[g.Table] -= size
+	p.plan.Unplaced = append(p.plan.Unplaced, Guest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests})
+}
+
+// Table for the guests of group (size seats) among the tables ok accepts
+// Their current table if they all share it and it still works, otherwise best fit, 0 if none has room
+func (p *planner) pick(group []int, size int, ok func(table int) bool) int {
+	current := p.guests[p.index[group[0]]].Table
+	for _, id := range group[1:] {
+		if p.guests[p.index[id]].Table != current {
+			current = 0
+		}
+	}
+
+	if _, exists := p.free[current]; exists && current != 0 && ok(current) && p.free[current] >= size {
+		return current
+	}
+
+	candidates := []Table{}
+	for _, t := range p.tables {
+		if ok(t.Number) {
+			candidates = append(candidates, Table{Number: t.Number, Seats: t.Seats, SeatsEmpty: p.free[t.Number]})
+		}
+	}
+
+	return pickBestFit(candidates, size)
+}
+
+// Checks a constraint names its guests (and tables, if pinned)
+func validConstraint(c Constraint) string {
+	guests := len(c.Guests) + len(c.IDs)
+
+	switch c.Type {
+	case "together", "apart":
+		if guests < 2 {
+			return c.Type + " needs at least two guests"
+		}
+	case "pinned":
+		if guests < 1 || len(c.Tables) < 1 {
+			return "pinned needs guests and tables"
+		}
+	default:
+		return "type must be together, apart or pinned"
+	}
+
+	return ""
+}
+
+/*
+### Seating plan
+
+Plans where the guests who haven't arrived yet should sit, with the rules given as constraints:
+together (the guests share a table), apart (no two of them share a table) and pinned (the guests sit at one of tables).
+Guests are named by name, or by id when a name is shared. Guests already at the venue stay where they are.
+Table capacities are always respected: constraints that can't all be met are reported in unsatisfied,
+and parties that fit on no table at all in unplaced.
+With ?apply=true the plan is carried out (moving the guests at once), unless some party is unplaced (http.StatusConflict).
+
+POST /venue/plan?apply=true
+body:
+{
+	"constraints": [
+		{
+			"type": "together|apart|pinned",
+			"guests": ["string", ...],
+			"ids": [int, ...],
+			"tables": [int, ...]
+		}, ...
+	]
+}
+response:
+{
+	"applied": bool,
+	"moves": [
+		{
+			"id": int,
+			"name": "string",
+			"from": int,
+			"to": int
+		}, ...
+	],
+	"unsatisfied": [
+		{
+			"constraint": int,
+			"type": "string",
+			"reason": "string"
+		}, ...
+	],
+	"unplaced": [
+		{
+			"id": int,
+			"name": "string",
+			"table": int,
+			"accompanying_guests": int
+		}, ...
+	],
+	"tables": [
+		{
+			"table_number": int,
+			"seats": int,
+			"seats_empty": int
+		}, ...
+	]
+}
+*/
+func (a *App) handlerPlanSeating(w http.ResponseWriter, r *http.Request) {
+
+	var req PlanRequest
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	for i, c := range req.Constraints {
+		if reason := validConstraint(c); reason != "" {
+			respondWithProblem(w, invalidParam("constraints", fmt.Sprintf("constraint %d: %s", i, reason)))
+			return
+		}
+	}
+
+	var plan SeatingPlan
+
+	// Only planning, on a consistent snapshot
+	if r.URL.Query().Get("apply") != "true" {
+		tables, guests, err := a.Store.GetSeating(0)
+		if err != nil {
+			respondWithProblem(w, err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, planSeating(tables, guests, req.Constraints))
+		return
+	}
+
+	// Planning and moving guests with the venue locked
+	err := a.Store.Reseat(func(tables []Table, guests []Guest) (map[int]int, error) {
+		plan = planSeating(tables, guests, req.Constraints)
+		if len(plan.Unplaced) > 0 {
+			return nil, ErrNoRoomToMove
+		}
+
+		return plan.moveMap(), nil
+	})
+
+	if errors.Is(err, ErrNoRoomToMove) && len(plan.Unplaced) > 0 {
+		respondWithJSON(w, http.StatusConflict, plan)
+		return
+	}
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	plan.Applied = true
+	a.promoteWaitlist()
+
+	respondWithJSON(w, http.StatusOK, plan)
+}
@@ -0,0 +1,146 @@
+// seating.go
+
+package main
+
+import "sort"
+
+// Picks the table for a party of size among tables (with their free seats), 0 if it fits nowhere
+type Strategy func(tables []Table, size int) int
+
+// Table assignment strategies by name, best_fit is the default
+var strategies = map[string]Strategy{
+	"best_fit":  pickBestFit,
+	"first_fit": pickFirstFit,
+	"balanced":  pickBalanced,
+}
+
+// The lowest numbered table with room for the party
+func pickFirstFit(tables []Table, size int) int {
+	for _, t := range tables {
+		if t.SeatsEmpty >= size {
+			return t.Number
+		}
+	}
+
+	return 0
+}
+
+// The table the party fills the most, leaving bigger gaps for bigger parties
+func pickBestFit(tables []Table, size int) int {
+	best := -1
+
+	for i, t := range tables {
+		if t.SeatsEmpty >= size && (best < 0 || t.SeatsEmpty < tables[best].SeatsEmpty) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return 0
+	}
+
+	return tables[best].Number
+}
+
+// The least occupied table (by share of its seats), so tables fill up evenly
+func pickBalanced(tables []Table, size int) int {
+	best := -1
+
+	for i, t := range tables {
+		if t.SeatsEmpty < size || t.Seats <= 0 {
+			continue
+		}
+
+		// used/seats < best used/best seats, without floats
+		if best < 0 || (t.Seats-t.SeatsEmpty)*tables[best].Seats < (tables[best].Seats-tables[best].SeatsEmpty)*t.Seats {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return 0
+	}
+
+	return tables[best].Number
+}
+
+// Seats taken by guest (g) and their entourage, none once they have left
+func partySize(g Guest) int {
+	if g.TimeLeft != "" {
+		return 0
+	}
+
+	return g.AccompanyingGuests + 1 // main guest is not accounted by AccompanyingGuests
+}
+
+// Whether parties (a) and (b) fit on each other's table, given the free seats (table_number -> free seats) of both
+func swapFits(a Guest, b Guest, free map[int]int) bool {
+	if a.Table == b.Table {
+		return true
+	}
+
+	return free[a.Table]+partySize(a)-partySize(b) >= 0 && free[b.Table]+partySize(b)-partySize(a) >= 0
+}
+
+// Checks moves (guest id -> table) only send guests to existing tables with room for them
+// Tables that were already over capacity (shrunk by an older version) may stay so, but can't get worse
+func checkMoves(tables []Table, guests []Guest, moves map[int]int) error {
+	seats := map[int]int{}
+	before := map[int]int{}
+	after := map[int]int{}
+
+	for _, t := range tables {
+		seats[t.Number] = t.Seats
+	}
+
+	for _, g := range guests {
+		table := g.Table
+		if to, ok := moves[g.ID]; ok {
+			if _, exists := seats[to]; !exists {
+				return ErrTableNotFound
+			}
+			table = to
+		}
+
+		before[g.Table] += partySize(g)
+		after[table] += partySize(g)
+	}
+
+	for table, used := range after {
+		if used > seats[table] && used > before[table] {
+			return ErrNoRoomToMove
+		}
+	}
+
+	return nil
+}
+
+// Assigns each party (largest first) to the first of tables with enough free seats
+// free (table_number -> free seats) is updated with the placements
+// Returns the parties with their new table, or ErrNoRoomToReassign if some party doesn't fit anywhere
+func firstFit(parties []Guest, tables []int, free map[int]int) ([]Guest, error) {
+	placed := make([]Guest, len(parties))
+	copy(placed, parties)
+
+	sort.SliceStable(placed, func(i, j int) bool { return placed[i].AccompanyingGuests > placed[j].AccompanyingGuests })
+
+	for i := range placed {
+		size := partySize(placed[i])
+		found := false
+
+		for _, table := range tables {
+			if free[table] >= size {
+				free[table] -= size
+				placed[i].Table = table
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, ErrNoRoomToReassign
+		}
+	}
+
+	return placed, nil
+}
@@ -0,0 +1,37 @@
+// seating_test.go
+
+package main
+
+import "testing"
+
+// Tests the table assignment strategies
+func TestStrategies(t *testing.T) {
+	tables := []Table{
+		{Number: 1, Seats: 10, SeatsEmpty: 8}, // 20% taken
+		{Number: 2, Seats: 4, SeatsEmpty: 3},  // 25% taken
+		{Number: 3, Seats: 12, SeatsEmpty: 6}, // 50% taken
+	}
+
+	tests := []struct {
+		strategy string
+		size     int
+		expected int
+	}{
+		{"first_fit", 3, 1},
+		{"best_fit", 3, 2},
+		{"best_fit", 4, 3},
+		{"balanced", 3, 1},
+		{"balanced", 9, 0},
+		{"first_fit", 9, 0},
+	}
+
+	for _, test := range tests {
+		if table := strategies[test.strategy](tables, test.size); table != test.expected {
+			t.Errorf("Expected %s to pick table %d for %d people. Got '%d'", test.strategy, test.expected, test.size, table)
+		}
+	}
+
+	if table := pickBalanced([]Table{{Number: 1, Seats: 10, SeatsEmpty: 5}, {Number: 2, Seats: 4, SeatsEmpty: 3}}, 2); table != 2 {
+		t.Errorf("Expected balanced to pick the less occupied table 2. Got '%d'", table)
+	}
+}
@@ -0,0 +1,125 @@
+// store.go
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Storage operations on the guestlist
+type GuestStore interface {
+	AddGuest(g *Guest) error                                // Adds a new guest to the guestlist if there are enough free seats at the table and nobody has the same name
+	InviteGuest(g *Guest) error                             // Adds a new guest to the guestlist if there are enough free seats at the table, names may repeat
+	AssignGuest(g *Guest, pick Strategy, unique bool) error // Adds a new guest to the table picked by strategy, with unique = true like AddGuest otherwise like InviteGuest
+	UpdateGuest(g *Guest) error                             // Marks the guest as arrived (again, if they had left), possibly with a different amount of accompanying guests
+	DepartGuest(name string) error                          // Records an arrived guest leaving, their seats are free again
+	CheckIn(name string, people int) (Guest, error)         // Adds people of a party to the venue, checking seats if the party outgrows its reservation
+	CheckOut(name string, people int) (Guest, error)        // Removes people of a party from the venue, the guest leaves with the last of them
+	GetMovements(name string) ([]Movement, error)           // Gets the arrivals, check-ins, check-outs and departures of a guest
+	GetGuest(name string) (Guest, error)                    // Gets a guest by name
+	GetGuestList() (GuestList, error)                       // Gets every guest on the guestlist
+	GetArrivedGuests() (GuestList, error)                   // Gets every guest that has arrived and not left
+	QueryGuests(q GuestQuery) (GuestPage, error)            // Gets a page of the guests matching the filters of q, in its order
+	DeleteGuest(name string) error                          // Removes a guest from the guestlist
+
+	// Guests are looked up by name above, failing with ErrAmbiguousGuest if several share it, and by id below
+	GetGuestByID(id int) (Guest, error)                // Gets a guest by id
+	FindGuests(name string) ([]Guest, error)           // Gets every guest called name
+	EditGuest(id int, e GuestEdit) (Guest, error)      // Renames a guest and/or moves them to another table with enough free seats
+	SwapGuests(first int, second int) ([]Guest, error) // Swaps the tables of two guests, if each party fits on the other's table
+	DeleteGuestByID(id int) error                      // Removes a guest from the guestlist by id
+
+	ImportGuests(guests []Guest, pick Strategy, unique bool, mode ImportMode) ([]error, error) // Adds many guests at once, reporting each one's error
+
+	StreamGuests(arrived bool, fn func(g Guest) error) error // Calls fn with every guest (only the arrived ones if arrived = true) as they are read, stopping at its first error
+}
+
+// Storage operations on the venue tables
+type VenueStore interface {
+	AddTable(seats int) error                                                    // Adds a new table with the given amount of seats
+	GetFreeSeats(table int, all bool) (int, error)                               // Counts free seats on a table, or on the whole venue if all = true
+	GetTables() ([]Table, error)                                                 // Lists every table
+	GetTable(table int) (Table, error)                                           // Gets a table by number
+	ResizeTable(table int, seats int) error                                      // Changes the seats of a table, never below the seats taken
+	DeleteTable(table int, reassign bool) ([]Guest, error)                       // Removes a table, reassigning its guests or refusing if it has any
+	GetSeating(table int) ([]Table, []Guest, error)                              // Gets a table (every table if 0) and its guests, read together
+	Reseat(plan func(tables []Table, guests []Guest) (map[int]int, error)) error // Moves guests (id -> table) as planned on a locked snapshot of the venue
+}
+
+// Storage operations on the waitlist
+type WaitlistStore interface {
+	AddToWaitlist(e *WaitlistEntry) error               // Queues a guest waiting for seats, refusing names on the guestlist if e.Unique, sets e.ID
+	GetWaitlist(promoted bool) ([]WaitlistEntry, error) // Gets the entries still waiting by priority, or the promoted ones
+	RemoveFromWaitlist(id int) error                    // Drops an entry still waiting
+	PromoteWaitlist() ([]WaitlistEntry, error)          // Adds every waiting entry that fits to the guestlist, highest priority first
+}
+
+// Storage backend used by the App
+type Store interface {
+	GuestStore
+	VenueStore
+	WaitlistStore
+
+	Ping(ctx context.Context) error // Checks the backend is reachable
+	Close() error                   // Releases any resources held by the backend
+}
+
+// Opens the storage backend selected by cfg.Backend ("mysql", "postgres", "sqlite" or "memory")
+// cfg.DSN is a mysql/postgres DSN or a sqlite file path, ignored by the memory backend
+func openStore(cfg Config) (Store, error) {
+	var s *sqlStore
+	var err error
+
+	switch cfg.Backend {
+	case "mysql":
+		s, err = newMySQLStore(cfg.DSN)
+	case "postgres":
+		s, err = newPostgresStore(cfg.DSN)
+	case "sqlite":
+		s, err = newSQLiteStore(cfg.DSN)
+	case "memory":
+		return newMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.SetMaxOpenConns(cfg.MaxOpenConns)
+	s.db.SetMaxIdleConns(cfg.MaxIdleConns)
+	s.db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+
+	return s, nil
+}
+
+// Pings store until it answers, backing off exponentially between attempts, for up to timeout
+func waitForStore(store Store, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := 100 * time.Millisecond
+
+	for {
+		err := store.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("database not ready (%v), retrying in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready after %s: %v", timeout, err)
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
@@ -0,0 +1,905 @@
+// store_memory.go
+
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// In-memory implementation of Store, used for development and tests
+type memoryStore struct {
+	mu        sync.Mutex
+	tables    map[int]int // table_number -> seats
+	nextTable int
+	guests    []Guest            // guestlist in insertion order
+	nextGuest int                // id of the next guest added
+	movements map[int][]Movement // guest id -> timeline
+	waitlist  []WaitlistEntry    // waiting and promoted entries in request order
+	nextEntry int                // id of the next waitlist entry
+}
+
+// Creates an empty in-memory store
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tables: map[int]int{}, nextTable: 1, nextGuest: 1, movements: map[int][]Movement{}, nextEntry: 1}
+}
+
+// Always reachable
+func (s *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Nothing to release
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// Adds a new table to the venue
+func (s *memoryStore) AddTable(seats int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tables[s.nextTable] = seats
+	s.nextTable++
+
+	return nil
+}
+
+// Lists every venue table with its free seats
+func (s *memoryStore) GetTables() ([]Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tableList(), nil
+}
+
+// Gets venue table (table) with its free seats
+func (s *memoryStore) GetTable(table int) (Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	free, err := s.freeSeats(table, false)
+	if err != nil {
+		return Table{Number: table}, err
+	}
+
+	return Table{Number: table, Seats: s.tables[table], SeatsEmpty: free}, nil
+}
+
+// Gets table (every table if 0) and the guests seated there, with their arrival and departure
+func (s *memoryStore) GetSeating(table int) ([]Table, []Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tables[table]; table != 0 && !ok {
+		return nil, nil, ErrTableNotFound
+	}
+
+	tables := []Table{}
+	for _, t := range s.tableList() {
+		if table == 0 || t.Number == table {
+			tables = append(tables, t)
+		}
+	}
+
+	guests := []Guest{}
+	for _, g := range s.guests {
+		if table == 0 || g.Table == table {
+			guests = append(guests, g)
+		}
+	}
+
+	return tables, guests, nil
+}
+
+// Moves guests as planned (guest id -> table) on a snapshot of every table and guest
+func (s *memoryStore) Reseat(plan func(tables []Table, guests []Guest) (map[int]int, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := s.tableList()
+	guests := append([]Guest{}, s.guests...)
+
+	moves, err := plan(tables, guests)
+	if err != nil {
+		return err
+	}
+
+	if err := checkMoves(tables, guests, moves); err != nil {
+		return err
+	}
+
+	for i := range s.guests {
+		if table, ok := moves[s.guests[i].ID]; ok {
+			s.guests[i].Table = table
+		}
+	}
+
+	return nil
+}
+
+// Changes the seats of table, refusing to go below the seats already taken
+func (s *memoryStore) ResizeTable(table int, seats int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	free, err := s.freeSeats(table, false)
+	if err != nil {
+		return err
+	}
+
+	if seats < s.tables[table]-free {
+		return ErrSeatsBelowOccupancy
+	}
+
+	s.tables[table] = seats
+
+	return nil
+}
+
+// Removes table from the venue
+// Its guests are moved to other tables with free seats if reassign = true, otherwise the table must be empty
+func (s *memoryStore) DeleteTable(table int, reassign bool) ([]Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tables[table]; !ok {
+		return nil, ErrTableNotFound
+	}
+
+	// departed guests stay on the guestlist with the removed table, as history
+	guests := []Guest{}
+	for _, g := range s.guests {
+		if g.Table == table && g.TimeLeft == "" {
+			guests = append(guests, Guest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests, TimeLeft: g.TimeLeft})
+		}
+	}
+
+	if len(guests) > 0 && !reassign {
+		return nil, ErrTableOccupied
+	}
+
+	free := map[int]int{}
+	others := []int{}
+	for _, t := range s.tableList() {
+		if t.Number != table {
+			free[t.Number] = t.SeatsEmpty
+			others = append(others, t.Number)
+		}
+	}
+
+	moved, err := firstFit(guests, others, free)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range moved {
+		s.guests[s.guestIndex(g.ID)].Table = g.Table
+	}
+
+	delete(s.tables, table)
+
+	return moved, nil
+}
+
+// Handles the addition of new guests to the guestlist, refusing names already on it
+func (s *memoryStore) AddGuest(g *Guest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findGuest(g.Name) >= 0 {
+		return ErrDuplicateGuest
+	}
+
+	return s.addGuest(g)
+}
+
+// Adds guest (g) to the guestlist, other guests may have the same name
+func (s *memoryStore) InviteGuest(g *Guest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addGuest(g)
+}
+
+// Adds guest (g) to the table picked among every table with their free seats
+func (s *memoryStore) AssignGuest(g *Guest, pick Strategy, unique bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if unique && s.findGuest(g.Name) >= 0 {
+		return ErrDuplicateGuest
+	}
+
+	if g.Table = pick(s.tableList(), g.AccompanyingGuests+1); g.Table == 0 {
+		return ErrTableFull
+	}
+
+	return s.addGuest(g)
+}
+
+// Adds guest (g) if there are enough free seats at their table, setting g.ID. Caller must hold s.mu
+func (s *memoryStore) addGuest(g *Guest) error {
+	freeSeats, err := s.freeSeats(g.Table, false)
+	if err != nil {
+		return err
+	}
+
+	// main guest is not accounted by AccompanyingGuests
+	if freeSeats-g.AccompanyingGuests-1 < 0 {
+		return ErrTableFull
+	}
+
+	g.ID = s.nextGuest
+	s.nextGuest++
+
+	s.guests = append(s.guests, Guest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests})
+
+	return nil
+}
+
+// Adds guests in order, every seat check seeing the rows added before
+// Guests without a table get the one picked among every table. Returns each guest's error (nil if added, setting its ID and Table),
+// the rows are only kept with importBestEffort, or with importAllOrNothing if none failed
+func (s *memoryStore) ImportGuests(guests []Guest, pick Strategy, unique bool, mode ImportMode) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(guests))
+	added, nextGuest := len(s.guests), s.nextGuest
+	failed := false
+
+	for i := range guests {
+		g := &guests[i]
+
+		if unique && s.findGuest(g.Name) >= 0 {
+			errs[i], failed = ErrDuplicateGuest, true
+			continue
+		}
+
+		if g.Table == 0 {
+			if g.Table = pick(s.tableList(), g.AccompanyingGuests+1); g.Table == 0 {
+				errs[i], failed = ErrTableFull, true
+				continue
+			}
+		}
+
+		if err := s.addGuest(g); err != nil {
+			errs[i], failed = err, true
+		}
+	}
+
+	// rolls back, only guests were appended
+	if mode == importValidate || (mode == importAllOrNothing && failed) {
+		s.guests, s.nextGuest = s.guests[:added], nextGuest
+	}
+
+	return errs, nil
+}
+
+// Sets time_arrived and the arrived flag, checking seats if the entourage changed
+func (s *memoryStore) UpdateGuest(g *Guest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(g.Name)
+	if err != nil {
+		return err
+	}
+
+	stored := &s.guests[i]
+	g.Table = stored.Table
+
+	// a guest coming back after leaving needs their seats again
+	if stored.TimeLeft != "" || stored.AccompanyingGuests != g.AccompanyingGuests {
+		freeSeats, err := s.freeSeats(stored.Table, false)
+		if err != nil {
+			return err
+		}
+
+		if stored.TimeLeft == "" {
+			freeSeats += stored.AccompanyingGuests + 1 // seats already taken by the guest
+		}
+
+		// if there aren't enough seats
+		if freeSeats-g.AccompanyingGuests-1 < 0 {
+			return ErrPartyTooBig
+		}
+
+		stored.AccompanyingGuests = g.AccompanyingGuests
+	}
+
+	stored.Arrived = 1
+	stored.TimeArrived = now()
+	stored.TimeLeft = ""
+	stored.Present = stored.AccompanyingGuests + 1
+
+	s.recordMovement(stored.ID, eventArrived, stored.Present, stored.Present)
+
+	return nil
+}
+
+// Records guest (name) leaving, keeping them on the guestlist
+func (s *memoryStore) DepartGuest(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return err
+	}
+
+	if s.guests[i].Arrived != 1 {
+		return ErrGuestNotHere
+	}
+
+	s.recordMovement(s.guests[i].ID, eventLeft, s.guests[i].Present, 0)
+
+	s.guests[i].Arrived = 0
+	s.guests[i].TimeLeft = now()
+	s.guests[i].Present = 0
+
+	return nil
+}
+
+// People of guest (name)'s party come in, the guest arrives with them if they weren't at the venue
+func (s *memoryStore) CheckIn(name string, people int) (Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	free, err := s.freeSeats(s.guests[i].Table, false)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	g, err := checkIn(s.guests[i], people, free)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	if s.guests[i].Arrived != 1 {
+		g.TimeArrived = now()
+	}
+
+	s.guests[i] = g
+	s.recordMovement(g.ID, eventCheckIn, people, g.Present)
+
+	return guestInfo(g), nil
+}
+
+// People of guest (name)'s party go out, the guest leaves with the last of them
+func (s *memoryStore) CheckOut(name string, people int) (Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	g, err := checkOut(s.guests[i], people)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	if g.Present == 0 {
+		g.Arrived = 0
+		g.TimeLeft = now()
+	}
+
+	s.guests[i] = g
+	s.recordMovement(g.ID, eventCheckOut, people, g.Present)
+
+	return guestInfo(g), nil
+}
+
+// Timeline of guest (name)
+func (s *memoryStore) GetMovements(name string) ([]Movement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Movement{}, s.movements[s.guests[i].ID]...), nil
+}
+
+// Returns every guest on the guestlist
+func (s *memoryStore) GetGuestList() (GuestList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gl := GuestList{Guests: []Guest{}}
+
+	for _, g := range s.guests {
+		gl.Guests = append(gl.Guests, Guest{Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests})
+	}
+
+	return gl, nil
+}
+
+// Returns every guest that has arrived
+func (s *memoryStore) GetArrivedGuests() (GuestList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gl := GuestList{Guests: []Guest{}}
+
+	for _, g := range s.guests {
+		if g.Arrived == 1 {
+			gl.Guests = append(gl.Guests, Guest{Name: g.Name, Table: g.Table, TimeArrived: g.TimeArrived})
+		}
+	}
+
+	return gl, nil
+}
+
+// Gets a page of the guests matching the filters of q
+func (s *memoryStore) QueryGuests(q GuestQuery) (GuestPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page := GuestPage{Guests: []Guest{}}
+	matching := []Guest{}
+
+	for _, g := range s.guests {
+		party := g.AccompanyingGuests + 1
+
+		switch {
+		case q.Table != 0 && g.Table != q.Table,
+			q.Arrived != nil && (g.Arrived == 1) != *q.Arrived,
+			!strings.HasPrefix(g.Name, q.NamePrefix),
+			q.MinParty != 0 && party < q.MinParty,
+			q.MaxParty != 0 && party > q.MaxParty,
+			!q.ArrivedAfter.IsZero() && (g.TimeArrived == "" || g.TimeArrived <= q.ArrivedAfter.UTC().Format(time.RFC3339)):
+			continue
+		}
+
+		matching = append(matching, guestInfo(g))
+	}
+
+	page.Total = len(matching)
+
+	// the sort key, then the id
+	before := func(a GuestCursor, b GuestCursor) bool {
+		if c := compareKeys(q.Sort, a.Key, b.Key); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+
+	sort.Slice(matching, func(i, j int) bool { return before(cursorOf(matching[i], q.Sort), cursorOf(matching[j], q.Sort)) })
+
+	for _, g := range matching {
+		if q.After != nil && !before(*q.After, cursorOf(g, q.Sort)) {
+			continue
+		}
+
+		if len(page.Guests) == q.Limit {
+			page.NextCursor = cursorOf(page.Guests[q.Limit-1], q.Sort).encode()
+			break
+		}
+		page.Guests = append(page.Guests, g)
+	}
+
+	return page, nil
+}
+
+// Compares sort keys (a) and (b) as the SQL backends order them, guests yet to arrive last on time_arrived
+func compareKeys(sort string, a string, b string) int {
+	switch sort {
+	case "table":
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	case "time_arrived":
+		if a == "" || b == "" {
+			return len(b) - len(a) // only one of them empty, it goes last
+		}
+	}
+
+	return strings.Compare(a, b)
+}
+
+// Guests copied at a time by StreamGuests, the lock isn't held while fn runs
+const streamBatch = 1000
+
+// Calls fn with every guest (only the arrived ones if arrived = true) in the order they were added, stopping at its first error
+func (s *memoryStore) StreamGuests(arrived bool, fn func(g Guest) error) error {
+	for after := 0; ; {
+		batch := s.guestsAfter(after, streamBatch)
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, g := range batch {
+			if arrived && g.Arrived != 1 {
+				continue
+			}
+
+			if err := fn(g); err != nil {
+				return err
+			}
+		}
+
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// Copies up to n guests with ids above after, the guestlist is in id order
+func (s *memoryStore) guestsAfter(after int, n int) []Guest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := sort.Search(len(s.guests), func(i int) bool { return s.guests[i].ID > after })
+	end := i + n
+	if end > len(s.guests) {
+		end = len(s.guests)
+	}
+
+	return append([]Guest(nil), s.guests[i:end]...)
+}
+
+// Removes guest (name) from the guestlist
+func (s *memoryStore) DeleteGuest(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return err
+	}
+
+	s.removeGuest(i)
+
+	return nil
+}
+
+// Get guest (id) from guestlist
+func (s *memoryStore) GetGuestByID(id int) (Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.guestIndex(id)
+	if i < 0 {
+		return Guest{}, ErrGuestNotFound
+	}
+
+	return guestInfo(s.guests[i]), nil
+}
+
+// Every guest called name, in insertion order
+func (s *memoryStore) FindGuests(name string) ([]Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guests := []Guest{}
+	for _, g := range s.guests {
+		if g.Name == name {
+			guests = append(guests, guestInfo(g))
+		}
+	}
+
+	return guests, nil
+}
+
+// Renames guest (id) and/or moves them to another table, which needs free seats for their party
+func (s *memoryStore) EditGuest(id int, e GuestEdit) (Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.guestIndex(id)
+	if i < 0 {
+		return Guest{}, ErrGuestNotFound
+	}
+
+	g := s.guests[i]
+
+	if e.Table != nil && *e.Table != g.Table {
+		free, err := s.freeSeats(*e.Table, false)
+		if err != nil {
+			return Guest{}, err
+		}
+
+		if free < partySize(g) {
+			return Guest{}, ErrNoRoomToMove
+		}
+
+		g.Table = *e.Table
+	}
+
+	if e.Name != nil {
+		g.Name = *e.Name
+	}
+
+	s.guests[i] = g
+
+	return guestInfo(g), nil
+}
+
+// Swaps the tables of guests (first) and (second), each table needs free seats for the party coming in
+func (s *memoryStore) SwapGuests(first int, second int) ([]Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, j := s.guestIndex(first), s.guestIndex(second)
+	if i < 0 || j < 0 {
+		return nil, ErrGuestNotFound
+	}
+
+	free := map[int]int{}
+	for _, t := range []int{s.guests[i].Table, s.guests[j].Table} {
+		seats, err := s.freeSeats(t, false)
+		if err != nil {
+			return nil, err
+		}
+		free[t] = seats
+	}
+
+	if !swapFits(s.guests[i], s.guests[j], free) {
+		return nil, ErrNoRoomToMove
+	}
+
+	s.guests[i].Table, s.guests[j].Table = s.guests[j].Table, s.guests[i].Table
+
+	return []Guest{guestInfo(s.guests[i]), guestInfo(s.guests[j])}, nil
+}
+
+// Removes guest (id) from the guestlist
+func (s *memoryStore) DeleteGuestByID(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.guestIndex(id)
+	if i < 0 {
+		return ErrGuestNotFound
+	}
+
+	s.removeGuest(i)
+
+	return nil
+}
+
+// Counts free seats on table, or on the whole venue if all = true
+func (s *memoryStore) GetFreeSeats(table int, all bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.freeSeats(table, all)
+}
+
+// Get guest (name) from guestlist
+func (s *memoryStore) GetGuest(name string) (Guest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.lookupGuest(name)
+	if err != nil {
+		return Guest{}, err
+	}
+
+	return guestInfo(s.guests[i]), nil
+}
+
+// Queues guest (e) waiting for seats, refusing names already on the guestlist if e.Unique, sets e.ID
+func (s *memoryStore) AddToWaitlist(e *WaitlistEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tables[e.Table]; e.Table != 0 && !ok {
+		return ErrTableNotFound
+	}
+
+	if e.Unique && s.findGuest(e.Name) >= 0 {
+		return ErrDuplicateGuest
+	}
+
+	e.ID = s.nextEntry
+	e.RequestedAt = now()
+	e.PromotedAt = ""
+	e.GuestID = 0
+	s.nextEntry++
+
+	s.waitlist = append(s.waitlist, *e)
+
+	return nil
+}
+
+// Gets the entries still waiting, highest priority first, or the promoted ones by promotion time
+func (s *memoryStore) GetWaitlist(promoted bool) ([]WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !promoted {
+		return s.waiting(), nil
+	}
+
+	entries := []WaitlistEntry{}
+	for _, e := range s.waitlist {
+		if e.PromotedAt != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].PromotedAt < entries[j].PromotedAt })
+
+	return entries, nil
+}
+
+// Drops waiting entry (id), promoted entries are kept as a record
+func (s *memoryStore) RemoveFromWaitlist(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range s.waitlist {
+		if e.ID == id && e.PromotedAt == "" {
+			s.waitlist = append(s.waitlist[:i], s.waitlist[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrEntryNotFound
+}
+
+// Adds every waiting entry that fits to the guestlist, highest priority first
+// Entries that don't fit (or whose name was taken meanwhile) keep waiting
+func (s *memoryStore) PromoteWaitlist() ([]WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promoted := []WaitlistEntry{}
+
+	for _, e := range s.waiting() {
+		if e.Unique && s.findGuest(e.Name) >= 0 {
+			continue
+		}
+
+		g := Guest{Name: e.Name, Table: e.Table, AccompanyingGuests: e.AccompanyingGuests}
+		if g.Table == 0 {
+			if g.Table = pickBestFit(s.tableList(), g.AccompanyingGuests+1); g.Table == 0 {
+				continue
+			}
+		}
+
+		if err := s.addGuest(&g); err != nil {
+			continue
+		}
+
+		for i := range s.waitlist {
+			if s.waitlist[i].ID == e.ID {
+				s.waitlist[i].Table, s.waitlist[i].GuestID, s.waitlist[i].PromotedAt = g.Table, g.ID, now()
+				promoted = append(promoted, s.waitlist[i])
+			}
+		}
+	}
+
+	return promoted, nil
+}
+
+// Entries still waiting, highest priority first. Caller must hold s.mu
+func (s *memoryStore) waiting() []WaitlistEntry {
+	entries := []WaitlistEntry{}
+	for _, e := range s.waitlist {
+		if e.PromotedAt == "" {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Priority > entries[j].Priority })
+
+	return entries
+}
+
+// Same accounting as the SQL backends: seats minus every guest and their entourage, except those who left. Caller must hold s.mu
+func (s *memoryStore) freeSeats(table int, all bool) (int, error) {
+	var freeSeats int
+
+	if all {
+		for _, seats := range s.tables {
+			freeSeats += seats
+		}
+	} else {
+		seats, ok := s.tables[table]
+		if !ok {
+			return 0, ErrTableNotFound
+		}
+		freeSeats = seats
+	}
+
+	for _, g := range s.guests {
+		if (all || g.Table == table) && g.TimeLeft == "" {
+			freeSeats -= g.AccompanyingGuests + 1
+		}
+	}
+
+	return freeSeats, nil
+}
+
+// Venue tables sorted by number, with their free seats. Caller must hold s.mu
+func (s *memoryStore) tableList() []Table {
+	tables := []Table{}
+
+	for number, seats := range s.tables {
+		free, _ := s.freeSeats(number, false)
+		tables = append(tables, Table{Number: number, Seats: seats, SeatsEmpty: free})
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Number < tables[j].Number })
+
+	return tables
+}
+
+// Index of the first guest called name on s.guests, -1 if missing. Caller must hold s.mu
+func (s *memoryStore) findGuest(name string) int {
+	for i, g := range s.guests {
+		if g.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Index of the only guest called name on s.guests, ErrAmbiguousGuest if the name is shared. Caller must hold s.mu
+func (s *memoryStore) lookupGuest(name string) (int, error) {
+	found := -1
+
+	for i, g := range s.guests {
+		if g.Name != name {
+			continue
+		}
+		if found >= 0 {
+			return -1, ErrAmbiguousGuest
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return -1, ErrGuestNotFound
+	}
+
+	return found, nil
+}
+
+// Index of guest (id) on s.guests, -1 if missing. Caller must hold s.mu
+func (s *memoryStore) guestIndex(id int) int {
+	for i, g := range s.guests {
+		if g.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Removes the guest at index (i) and their timeline. Caller must hold s.mu
+func (s *memoryStore) removeGuest(i int) {
+	delete(s.movements, s.guests[i].ID)
+	s.guests = append(s.guests[:i], s.guests[i+1:]...)
+}
+
+// Appends a movement to the timeline of guest (id). Caller must hold s.mu
+func (s *memoryStore) recordMovement(id int, event string, people int, present int) {
+	s.movements[id] = append(s.movements[id], Movement{Event: event, People: people, Present: present, Time: now()})
+}
+
+// Guest as GetGuest reports it
+func guestInfo(g Guest) Guest {
+	return Guest{ID: g.ID, Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests, TimeArrived: g.TimeArrived, Arrived: g.Arrived, TimeLeft: g.TimeLeft, Present: g.Present}
+}
+
+// Current time as stored on time_arrived (UTC, second precision)
+func now() string {
+	return time.Now().UTC().Truncate(time.Second).Format(time.RFC3339)
+}
@@ -0,0 +1,70 @@
+// store_memory_test.go
+
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Tests the in-memory seat accounting against getFreeSeats semantics
+func TestMemoryStoreFreeSeats(t *testing.T) {
+	s := newMemoryStore()
+	s.AddTable(10)
+	s.AddTable(4)
+
+	if err := s.AddGuest(&Guest{Name: "A", Table: 1, AccompanyingGuests: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGuest(&Guest{Name: "B", Table: 2, AccompanyingGuests: 4}); err != ErrTableFull {
+		t.Errorf("Expected ErrTableFull. Got '%v'", err)
+	}
+	if err := s.AddGuest(&Guest{Name: "A", Table: 2}); err != ErrDuplicateGuest {
+		t.Errorf("Expected ErrDuplicateGuest. Got '%v'", err)
+	}
+	if err := s.AddGuest(&Guest{Name: "C", Table: 3}); err != ErrTableNotFound {
+		t.Errorf("Expected ErrTableNotFound. Got '%v'", err)
+	}
+
+	if free, _ := s.GetFreeSeats(1, false); free != 6 {
+		t.Errorf("Expected 6 free seats on table 1. Got '%d'", free)
+	}
+	if free, _ := s.GetFreeSeats(0, true); free != 10 {
+		t.Errorf("Expected 10 free seats. Got '%d'", free)
+	}
+
+	// arriving with a bigger entourage takes more seats
+	if err := s.UpdateGuest(&Guest{Name: "A", AccompanyingGuests: 10}); err != ErrPartyTooBig {
+		t.Errorf("Expected ErrPartyTooBig. Got '%v'", err)
+	}
+	if err := s.UpdateGuest(&Guest{Name: "A", AccompanyingGuests: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if free, _ := s.GetFreeSeats(1, false); free != 0 {
+		t.Errorf("Expected 0 free seats on table 1. Got '%d'", free)
+	}
+}
+
+// Tests that concurrent additions never overbook a table (run with -race)
+func TestMemoryStoreConcurrentAddGuest(t *testing.T) {
+	s := newMemoryStore()
+	s.AddTable(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.AddGuest(&Guest{Name: "Guest" + strconv.Itoa(i), Table: 1, AccompanyingGuests: 1})
+		}(i)
+	}
+	wg.Wait()
+
+	if free, _ := s.GetFreeSeats(1, false); free != 0 {
+		t.Errorf("Expected 0 free seats. Got '%d'", free)
+	}
+	if gl, _ := s.GetGuestList(); len(gl.Guests) != 5 {
+		t.Errorf("Expected 5 guests. Got '%d'", len(gl.Guests))
+	}
+}
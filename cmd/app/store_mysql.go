@@ -0,0 +1,53 @@
+// store_mysql.go
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL flavour of SQL
+var mysqlDialect = dialect{
+	name:      "mysql",
+	driver:    "mysql",
+	now:       "NOW()",
+	forUpdate: " FOR UPDATE",
+	lockName:  "INSERT INTO guest_name_locks (guest_name) VALUES (?) ON DUPLICATE KEY UPDATE guest_name = guest_name",
+
+	// repeatable read would keep reading the snapshot taken before waiting, and lock gaps of the guest_name index
+	isolation: sql.LevelReadCommitted,
+
+	constraintError: mysqlConstraintError,
+}
+
+// Model error for the MySQL constraint errors: duplicate entry, and foreign keys on insert/update (1452) or delete (1451)
+func mysqlConstraintError(err error) error {
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	switch e.Number {
+	case 1062:
+		return ErrDuplicateGuest
+	case 1451, 1452:
+		return ErrBrokenReference
+	}
+
+	return nil
+}
+
+// Builds the mysql data source with login credentials (user, password), address (host, port) and database name (dbname)
+func mysqlDataSource(user string, password string, host string, port string, dbname string) string {
+	//"user:password@tcp(host:port)/database?parseTime=true"
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, dbname)
+}
+
+// Opens a mysql connection pool on dataSource
+func newMySQLStore(dataSource string) (*sqlStore, error) {
+	return newSQLStore(mysqlDialect, dataSource)
+}
@@ -0,0 +1,693 @@
+// store_sql.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQL databases differ on a few bits of syntax, described by their dialect
+type dialect struct {
+	name           string // backend name, also the directory of its migrations
+	driver         string // database/sql driver name
+	now            string // expression for the current timestamp
+	numberedParams bool   // placeholders are $1, $2, ... instead of ?
+	forUpdate      string // row locking clause appended to SELECTs, empty if locking is done by the transaction itself
+	lockName       string // upsert of a guest_name_locks row that leaves it locked until the transaction ends
+	returningID    bool   // new ids are read with INSERT ... RETURNING id, the driver has no LastInsertId
+	timeLayout     string // timestamps are bound as text in this layout, empty to bind time.Time
+
+	isolation       sql.IsolationLevel    // of every transaction, reads after waiting on a lock must see what its holder committed
+	constraintError func(err error) error // model error for a constraint the database refused, nil for any other error
+}
+
+// Implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// database/sql implementation of Store, shared by the SQL backends
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// Opens a connection pool on dataSource
+func newSQLStore(d dialect, dataSource string) (*sqlStore, error) {
+	db, err := sql.Open(d.driver, dataSource)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlStore{db: db, dialect: d}, nil
+}
+
+// Checks a connection to the database can be established
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Closes the connection pool
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// Adds a new table to the venue table
+func (s *sqlStore) AddTable(seats int) error {
+	_, err := s.db.Exec(s.rebind("INSERT INTO venue (seats) values (?)"), seats)
+
+	return err
+}
+
+// Handles the addition of new guests to the guestlist, refusing names already on it
+func (s *sqlStore) AddGuest(g *Guest) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return s.addGuest(tx, g, true)
+	})
+}
+
+// Adds guest (g) to the guestlist, other guests may have the same name
+func (s *sqlStore) InviteGuest(g *Guest) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return s.addGuest(tx, g, false)
+	})
+}
+
+// Adds guest (g) to the table picked among every table with their free seats, all locked while picking
+func (s *sqlStore) AssignGuest(g *Guest, pick Strategy, unique bool) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		tables, err := s.tables(tx, true)
+		if err != nil {
+			return err
+		}
+
+		if g.Table = pick(tables, g.AccompanyingGuests+1); g.Table == 0 {
+			return ErrTableFull
+		}
+
+		return s.addGuest(tx, g, unique)
+	})
+}
+
+// Adds guest (g) inside transaction (tx), the seat check and the insert happen under the table's lock
+// With unique = true the name can't be on the guestlist already, checked under the name's lock, sets g.ID
+func (s *sqlStore) addGuest(tx *sql.Tx, g *Guest, unique bool) error {
+
+	// names aren't UNIQUE on the database anymore: adding the same name (to any table) waits on its guest_name_locks row
+	if unique {
+		if _, err := tx.Exec(s.rebind(s.dialect.lockName), g.Name); err != nil {
+			return err
+		}
+	}
+
+	// Checking number of free seats instead of relying on DBs strict mode with UNSIGNED
+	freeSeats, err := s.freeSeats(tx, g.Table, false, true)
+	freeSeats = freeSeats - g.AccompanyingGuests - 1 // main guest is not accounted by AccompanyingGuests
+
+	if err != nil {
+		return err
+	}
+
+	// if there aren't enough sits
+	if freeSeats < 0 {
+		return ErrTableFull
+	}
+
+	// read committed: guests added by whoever held the name's lock before are seen
+	if unique {
+		var id int
+		switch err := tx.QueryRow(s.rebind("SELECT id FROM guestlist WHERE guest_name = ? LIMIT 1"), g.Name).Scan(&id); err {
+		case sql.ErrNoRows:
+		case nil:
+			return ErrDuplicateGuest
+		default:
+			return err
+		}
+	}
+
+	// Adds guest to guestlist table
+	g.ID, err = s.insert(tx, "INSERT INTO guestlist (guest_name, table_number, accompanying_guests, arrived) values (?, ?, ?, ?)", g.Name, g.Table, g.AccompanyingGuests, false)
+
+	return err
+}
+
+// Updates DB entry with time_arrived and sets arrived flag to "true"
+func (s *sqlStore) UpdateGuest(g *Guest) error {
+	return s.withGuestLock(g.Name, func(tx *sql.Tx, id int, stored Guest, freeSeats int) error {
+		g.Table = stored.Table
+
+		// if there are no changes in accompanying guests and the guest is still in, doesn't check sits
+		// else checks sits
+		if stored.TimeLeft != "" || stored.AccompanyingGuests != g.AccompanyingGuests {
+
+			freeSeats = freeSeats + partySize(stored) - g.AccompanyingGuests - 1 // new free seats count
+
+			// if there aren't enough sits
+			if freeSeats < 0 {
+				return ErrPartyTooBig
+			}
+		}
+
+		// updates guest on DB, the whole party is in
+		present := g.AccompanyingGuests + 1
+		_, err := tx.Exec(s.rebind("UPDATE guestlist SET accompanying_guests=?, time_arrived="+s.dialect.now+", arrived=?, time_left=NULL, present=? WHERE id=?"), g.AccompanyingGuests, true, present, id)
+		if err != nil {
+			return err
+		}
+
+		return s.recordMovement(tx, id, eventArrived, present, present)
+	})
+}
+
+// Sets time_left and clears the arrived flag, the guest stays on the guestlist
+func (s *sqlStore) DepartGuest(name string) error {
+	return s.withGuestLock(name, func(tx *sql.Tx, id int, g Guest, freeSeats int) error {
+		if g.Arrived != 1 {
+			return ErrGuestNotHere
+		}
+
+		_, err := tx.Exec(s.rebind("UPDATE guestlist SET arrived=?, time_left="+s.dialect.now+", present=0 WHERE id=?"), false, id)
+		if err != nil {
+			return err
+		}
+
+		return s.recordMovement(tx, id, eventLeft, g.Present, 0)
+	})
+}
+
+// Runs fn in a transaction holding the lock on guest (name)'s table, with the guest's row id, the guest and the table's free seats
+func (s *sqlStore) withGuestLock(name string, fn func(tx *sql.Tx, id int, g Guest, freeSeats int) error) error {
+	return retryMoved(func() error {
+
+		// Get the guest's table, so it can be locked before checking seats
+		id, table, err := s.findGuest(name)
+		if err != nil {
+			return err
+		}
+
+		return s.withTx(func(tx *sql.Tx) error {
+
+			// Checking number of free seats, locks the table until commit
+			freeSeats, err := s.freeSeats(tx, table, false, true)
+			if err != nil {
+				return err
+			}
+
+			g, err := s.tableGuest(tx, id, table)
+			if err != nil {
+				return err
+			}
+
+			return fn(tx, id, g, freeSeats)
+		})
+	})
+}
+
+// Id and table of the only guest called name, ErrAmbiguousGuest if the name is shared
+func (s *sqlStore) findGuest(name string) (int, int, error) {
+	rows, err := s.db.Query(s.rebind("SELECT id, table_number FROM guestlist WHERE guest_name = ? LIMIT 2"), name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var id, table, found int
+	for rows.Next() {
+		if err := rows.Scan(&id, &table); err != nil {
+			return 0, 0, err
+		}
+		found++
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	switch found {
+	case 0:
+		return 0, 0, ErrGuestNotFound
+	case 1:
+		return id, table, nil
+	}
+
+	return 0, 0, ErrAmbiguousGuest
+}
+
+// Columns read by scanGuest
+const guestColumns = "id, guest_name, table_number, accompanying_guests, arrived, time_arrived, time_left, present"
+
+// Reads a guestlist row selected with guestColumns
+func (s *sqlStore) scanGuest(row interface{ Scan(dest ...interface{}) error }) (Guest, error) {
+	var g Guest
+	var arrived bool
+	var timeArrived, timeLeft sql.NullString
+
+	if err := row.Scan(&g.ID, &g.Name, &g.Table, &g.AccompanyingGuests, &arrived, &timeArrived, &timeLeft, &g.Present); err != nil {
+		return g, err
+	}
+
+	// drivers disagree on BOOLEAN columns (tinyint on mysql), the API reports it as 0/1
+	if arrived {
+		g.Arrived = 1
+	}
+	g.TimeArrived = timeArrived.String
+	g.TimeLeft = timeLeft.String
+
+	return g, nil
+}
+
+// Runs insert (query) inside transaction (tx) and returns the new row's id
+func (s *sqlStore) insert(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	var id int
+
+	if s.dialect.returningID {
+		err := tx.QueryRow(s.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := tx.Exec(s.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+
+	return int(lastID), err
+}
+
+// Queries databse and returns a GuestList struct with all guests on the guestlist table
+func (s *sqlStore) GetGuestList() (GuestList, error) {
+	gl := GuestList{}
+	gl.Guests = []Guest{}
+
+	// Get all guests from guestlist
+	rows, err := s.db.Query(s.rebind("SELECT guest_name, table_number, accompanying_guests FROM guestlist"))
+
+	if err != nil {
+		return gl, err
+	}
+
+	defer rows.Close()
+
+	// Foreach guest
+	for rows.Next() {
+		var g Guest
+
+		if err := rows.Scan(&g.Name, &g.Table, &g.AccompanyingGuests); err != nil {
+			return gl, err
+		}
+
+		gl.Guests = append(gl.Guests, g)
+	}
+
+	return gl, nil // append guest to GuestList
+}
+
+// Queries databse and returns a GuestList struct with arrived guests
+func (s *sqlStore) GetArrivedGuests() (GuestList, error) {
+	gl := GuestList{}
+	gl.Guests = []Guest{}
+
+	// Get all guests with arrived=true from guestlist
+	rows, err := s.db.Query(s.rebind("SELECT guest_name, table_number, time_arrived FROM guestlist WHERE arrived=?"), true)
+
+	if err != nil {
+		return gl, err
+	}
+
+	defer rows.Close()
+
+	// Foreach guest
+	for rows.Next() {
+		var g Guest
+
+		if err := rows.Scan(&g.Name, &g.Table, &g.TimeArrived); err != nil {
+			return gl, err
+		}
+
+		gl.Guests = append(gl.Guests, g) // append guest to GuestList
+	}
+
+	return gl, nil
+}
+
+// Deletes guest entry from DB
+func (s *sqlStore) DeleteGuest(name string) error {
+	id, _, err := s.findGuest(name)
+	if err != nil {
+		return err
+	}
+
+	return s.DeleteGuestByID(id)
+}
+
+// Deletes guest (id) from DB, their movements go with them
+func (s *sqlStore) DeleteGuestByID(id int) error {
+
+	res, err := s.db.Exec(s.rebind("DELETE FROM guestlist WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrGuestNotFound
+	}
+
+	return nil
+}
+
+// Queries database for the number of free seats
+// If all = false, returns amount of free seats on table
+// If all = true, returns all available seats
+func (s *sqlStore) GetFreeSeats(table int, all bool) (int, error) {
+	return s.freeSeats(s.db, table, all, false)
+}
+
+// Counts free seats using q (the pool or a transaction)
+// With lock = true the table's venue row stays locked until the transaction ends
+func (s *sqlStore) freeSeats(q querier, table int, all bool, lock bool) (int, error) {
+
+	var freeSeats int
+	var usedSeats int
+	var err error
+
+	if all { // Get free seats
+
+		// query DB for available sits
+		err = q.QueryRow(s.rebind("SELECT COALESCE(SUM(seats), 0) FROM venue")).Scan(&freeSeats)
+		if err != nil {
+			return 0, err
+		}
+
+		// query DB for used sits
+		err = q.QueryRow(s.rebind("SELECT COALESCE(SUM(accompanying_guests + 1), 0) FROM guestlist WHERE time_left IS NULL")).Scan(&usedSeats)
+
+	} else { // Get free seats from specified table number
+
+		// query DB for available on specified table
+		if freeSeats, err = s.tableSeats(q, table, lock); err != nil {
+			return 0, err
+		}
+
+		// query DB for used sits on specified table
+		usedSeats, err = s.usedSeats(q, table)
+	}
+
+	return freeSeats - usedSeats, err
+}
+
+// Seats of table, with lock = true its venue row stays locked until the transaction (q) ends
+func (s *sqlStore) tableSeats(q querier, table int, lock bool) (int, error) {
+	query := "SELECT seats FROM venue WHERE table_number=?"
+	if lock {
+		query += s.dialect.forUpdate
+	}
+
+	var seats int
+	err := q.QueryRow(s.rebind(query), table).Scan(&seats)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrTableNotFound
+	}
+
+	return seats, err
+}
+
+// Seats taken on table by guests and their entourage, those who left don't take any
+func (s *sqlStore) usedSeats(q querier, table int) (int, error) {
+	var used int
+	err := q.QueryRow(s.rebind("SELECT COALESCE(SUM(accompanying_guests + 1), 0) FROM guestlist WHERE table_number=? AND time_left IS NULL"), table).Scan(&used)
+
+	return used, err
+}
+
+// Get guest (name) from guestlist
+func (s *sqlStore) GetGuest(name string) (Guest, error) {
+	id, _, err := s.findGuest(name)
+	if err != nil {
+		return Guest{Name: name}, err
+	}
+
+	return s.GetGuestByID(id)
+}
+
+// Get guest (id) from guestlist
+func (s *sqlStore) GetGuestByID(id int) (Guest, error) {
+	g, err := s.scanGuest(s.db.QueryRow(s.rebind("SELECT "+guestColumns+" FROM guestlist WHERE id = ?"), id))
+
+	if err == sql.ErrNoRows {
+		return g, ErrGuestNotFound
+	}
+
+	return g, err
+}
+
+// Every guest called name, oldest first
+func (s *sqlStore) FindGuests(name string) ([]Guest, error) {
+	guests := []Guest{}
+
+	rows, err := s.db.Query(s.rebind("SELECT "+guestColumns+" FROM guestlist WHERE guest_name = ? ORDER BY id"), name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := s.scanGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, g)
+	}
+
+	return guests, rows.Err()
+}
+
+// Renames guest (id) and/or moves them to another table, which needs free seats for their party
+func (s *sqlStore) EditGuest(id int, e GuestEdit) (Guest, error) {
+	err := retryMoved(func() error {
+		table, err := s.guestTable(id)
+		if err != nil {
+			return err
+		}
+
+		target := table
+		if e.Table != nil {
+			target = *e.Table
+		}
+
+		return s.withTx(func(tx *sql.Tx) error {
+			free, err := s.lockTables(tx, table, target)
+			if err != nil {
+				return err
+			}
+
+			g, err := s.tableGuest(tx, id, table)
+			if err != nil {
+				return err
+			}
+
+			if target != table {
+				if free[target] < partySize(g) {
+					return ErrNoRoomToMove
+				}
+
+				if _, err := tx.Exec(s.rebind("UPDATE guestlist SET table_number=? WHERE id=?"), target, id); err != nil {
+					return err
+				}
+			}
+
+			if e.Name != nil {
+				if _, err := tx.Exec(s.rebind("UPDATE guestlist SET guest_name=? WHERE id=?"), *e.Name, id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return Guest{}, err
+	}
+
+	return s.GetGuestByID(id)
+}
+
+// Swaps the tables of guests (first) and (second), each table needs free seats for the party coming in
+func (s *sqlStore) SwapGuests(first int, second int) ([]Guest, error) {
+	err := retryMoved(func() error {
+		firstTable, err := s.guestTable(first)
+		if err != nil {
+			return err
+		}
+
+		secondTable, err := s.guestTable(second)
+		if err != nil {
+			return err
+		}
+
+		return s.withTx(func(tx *sql.Tx) error {
+			free, err := s.lockTables(tx, firstTable, secondTable)
+			if err != nil {
+				return err
+			}
+
+			a, err := s.tableGuest(tx, first, firstTable)
+			if err != nil {
+				return err
+			}
+
+			b, err := s.tableGuest(tx, second, secondTable)
+			if err != nil {
+				return err
+			}
+
+			if !swapFits(a, b, free) {
+				return ErrNoRoomToMove
+			}
+
+			for _, move := range [][2]int{{secondTable, first}, {firstTable, second}} {
+				if _, err := tx.Exec(s.rebind("UPDATE guestlist SET table_number=? WHERE id=?"), move[0], move[1]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	guests := []Guest{}
+	for _, id := range []int{first, second} {
+		g, err := s.GetGuestByID(id)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, g)
+	}
+
+	return guests, nil
+}
+
+// Reads guest (id) inside transaction (tx) once their table is locked
+// errGuestMoved if they were removed or moved off table since it was first read, for retryMoved to look them up again
+func (s *sqlStore) tableGuest(tx *sql.Tx, id int, table int) (Guest, error) {
+	g, err := s.scanGuest(tx.QueryRow(s.rebind("SELECT "+guestColumns+" FROM guestlist WHERE id = ? AND table_number = ?"), id, table))
+
+	if err == sql.ErrNoRows {
+		return g, errGuestMoved
+	}
+
+	return g, err
+}
+
+// A guest left the table locked for them before the lock was taken
+var errGuestMoved = &Error{Kind: ErrorConflict, Code: "guest_moved", Message: "guest moved to another table meanwhile, try again"}
+
+// Times a change is tried again after its guest moved, a guest moving on every attempt gets errGuestMoved
+const movedRetries = 10
+
+// Runs change (looking up the guest's table and locking it) again while the guest moves away before it's locked,
+// a guest removed meanwhile is reported by the lookup (ErrGuestNotFound)
+func retryMoved(change func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := change(); !errors.Is(err, errGuestMoved) || attempt == movedRetries {
+			return err
+		}
+	}
+}
+
+// Table of guest (id)
+func (s *sqlStore) guestTable(id int) (int, error) {
+	var table int
+	err := s.db.QueryRow(s.rebind("SELECT table_number FROM guestlist WHERE id = ?"), id).Scan(&table)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrGuestNotFound
+	}
+
+	return table, err
+}
+
+// Locks tables in ascending order (like DeleteTable), so transactions locking several never deadlock
+// Returns the free seats of each table
+func (s *sqlStore) lockTables(tx *sql.Tx, tables ...int) (map[int]int, error) {
+	sorted := append([]int{}, tables...)
+	sort.Ints(sorted)
+
+	free := map[int]int{}
+	for _, t := range sorted {
+		if _, ok := free[t]; ok {
+			continue
+		}
+
+		seats, err := s.freeSeats(tx, t, false, true)
+		if err != nil {
+			return nil, err
+		}
+		free[t] = seats
+	}
+
+	return free, nil
+}
+
+// Runs fn inside a transaction, committing if it succeeds and rolling back otherwise
+func (s *sqlStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: s.dialect.isolation})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return s.modelError(err)
+	}
+
+	return s.modelError(tx.Commit())
+}
+
+// Constraints the database refused as model errors, so driver errors don't reach clients; other errors as they are
+func (s *sqlStore) modelError(err error) error {
+	if err == nil || s.dialect.constraintError == nil {
+		return err
+	}
+
+	if e := s.dialect.constraintError(err); e != nil {
+		return e
+	}
+
+	return err
+}
+
+// Rewrites the ? placeholders of query for dialects with numbered parameters
+func (s *sqlStore) rebind(query string) string {
+	if !s.dialect.numberedParams {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,65 @@
+// store_sql_import.go
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Rolls back an import that must not be kept
+var errImportRollback = errors.New("import rolled back")
+
+// Adds guests in order inside one transaction, every seat check seeing the rows added before
+// Guests without a table get the one picked among every table. Returns each guest's error (nil if added, setting its ID and Table),
+// the rows are only kept with importBestEffort, or with importAllOrNothing if none failed
+func (s *sqlStore) ImportGuests(guests []Guest, pick Strategy, unique bool, mode ImportMode) ([]error, error) {
+	errs := make([]error, len(guests))
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		tables, err := s.tables(tx, true)
+		if err != nil {
+			return err
+		}
+
+		failed := false
+
+		for i := range guests {
+			g := &guests[i]
+
+			if g.Table == 0 {
+				if g.Table = pick(tables, g.AccompanyingGuests+1); g.Table == 0 {
+					errs[i], failed = ErrTableFull, true
+					continue
+				}
+			}
+
+			switch err := s.addGuest(tx, g, unique); {
+			case err == nil:
+			case errors.Is(err, ErrTableFull), errors.Is(err, ErrTableNotFound), errors.Is(err, ErrDuplicateGuest):
+				errs[i], failed = err, true
+				continue
+			default:
+				return err
+			}
+
+			for j := range tables {
+				if tables[j].Number == g.Table {
+					tables[j].SeatsEmpty -= g.AccompanyingGuests + 1
+				}
+			}
+		}
+
+		if mode == importValidate || (mode == importAllOrNothing && failed) {
+			return errImportRollback
+		}
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return nil, err
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,94 @@
+// store_sql_movements.go
+
+package main
+
+import "database/sql"
+
+// People of guest (name)'s party come in, the guest arrives with them if they weren't at the venue
+func (s *sqlStore) CheckIn(name string, people int) (Guest, error) {
+	var g Guest
+
+	err := s.withGuestLock(name, func(tx *sql.Tx, id int, stored Guest, freeSeats int) error {
+		var err error
+		if g, err = checkIn(stored, people, freeSeats); err != nil {
+			return err
+		}
+
+		query := "UPDATE guestlist SET accompanying_guests=?, arrived=?, time_left=NULL, present=?"
+		if stored.Arrived != 1 {
+			query += ", time_arrived=" + s.dialect.now
+		}
+
+		if _, err := tx.Exec(s.rebind(query+" WHERE id=?"), g.AccompanyingGuests, true, g.Present, id); err != nil {
+			return err
+		}
+
+		return s.recordMovement(tx, id, eventCheckIn, people, g.Present)
+	})
+
+	if err != nil {
+		return Guest{}, err
+	}
+
+	return s.GetGuest(name)
+}
+
+// People of guest (name)'s party go out, the guest leaves with the last of them
+func (s *sqlStore) CheckOut(name string, people int) (Guest, error) {
+	err := s.withGuestLock(name, func(tx *sql.Tx, id int, stored Guest, freeSeats int) error {
+		g, err := checkOut(stored, people)
+		if err != nil {
+			return err
+		}
+
+		query := "UPDATE guestlist SET present=?, arrived=?"
+		if g.Present == 0 {
+			query += ", time_left=" + s.dialect.now
+		}
+
+		if _, err := tx.Exec(s.rebind(query+" WHERE id=?"), g.Present, g.Present > 0, id); err != nil {
+			return err
+		}
+
+		return s.recordMovement(tx, id, eventCheckOut, people, g.Present)
+	})
+
+	if err != nil {
+		return Guest{}, err
+	}
+
+	return s.GetGuest(name)
+}
+
+// Timeline of guest (name), oldest first
+func (s *sqlStore) GetMovements(name string) ([]Movement, error) {
+	id, _, err := s.findGuest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	movements := []Movement{}
+
+	rows, err := s.db.Query(s.rebind("SELECT event, people, present, moved_at FROM guest_movements WHERE guest_id = ? ORDER BY id"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Movement
+		if err := rows.Scan(&m.Event, &m.People, &m.Present, &m.Time); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, rows.Err()
+}
+
+// Appends a movement to the timeline of guestlist row (id) inside transaction (tx)
+func (s *sqlStore) recordMovement(tx *sql.Tx, id int, event string, people int, present int) error {
+	_, err := tx.Exec(s.rebind("INSERT INTO guest_movements (guest_id, event, people, present, moved_at) values (?, ?, ?, ?, "+s.dialect.now+")"), id, event, people, present)
+
+	return err
+}
@@ -0,0 +1,160 @@
+// store_sql_query.go
+
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Order of each guest sort, ending with the id so pages never overlap
+var guestOrders = map[string]string{
+	"id":           "id",
+	"name":         "guest_name, id",
+	"table":        "table_number, id",
+	"time_arrived": "CASE WHEN time_arrived IS NULL THEN 1 ELSE 0 END, time_arrived, id",
+}
+
+// Gets a page of the guests matching the filters of q, the total is counted on the same snapshot
+func (s *sqlStore) QueryGuests(q GuestQuery) (GuestPage, error) {
+	page := GuestPage{Guests: []Guest{}}
+
+	where, args := s.guestFilters(q)
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := tx.QueryRow(s.rebind("SELECT COUNT(*) FROM guestlist"+where), args...).Scan(&page.Total); err != nil {
+			return err
+		}
+
+		// the page starts after the cursor
+		if q.After != nil {
+			condition, cursorArgs := s.afterCursor(*q.After)
+			where, args = joinFilters(where, condition), append(args, cursorArgs...)
+		}
+
+		// one more row tells whether there's a next page
+		query := "SELECT " + guestColumns + " FROM guestlist" + where + " ORDER BY " + guestOrders[q.Sort] + " LIMIT " + strconv.Itoa(q.Limit+1)
+
+		rows, err := tx.Query(s.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			g, err := s.scanGuest(rows)
+			if err != nil {
+				return err
+			}
+			page.Guests = append(page.Guests, g)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return GuestPage{}, err
+	}
+
+	if len(page.Guests) > q.Limit {
+		page.Guests = page.Guests[:q.Limit]
+		page.NextCursor = cursorOf(page.Guests[q.Limit-1], q.Sort).encode()
+	}
+
+	return page, nil
+}
+
+// WHERE clause (empty if there's nothing to filter) and its arguments for the filters of q
+func (s *sqlStore) guestFilters(q GuestQuery) (string, []interface{}) {
+	where := ""
+	args := []interface{}{}
+
+	if q.Table != 0 {
+		where, args = joinFilters(where, "table_number = ?"), append(args, q.Table)
+	}
+	if q.Arrived != nil {
+		where, args = joinFilters(where, "arrived = ?"), append(args, *q.Arrived)
+	}
+	if q.NamePrefix != "" {
+		// SUBSTR counts characters on every backend, unlike LIKE it needs no escaping
+		where, args = joinFilters(where, "SUBSTR(guest_name, 1, ?) = ?"), append(args, len([]rune(q.NamePrefix)), q.NamePrefix)
+	}
+	if q.MinParty != 0 {
+		where, args = joinFilters(where, "accompanying_guests + 1 >= ?"), append(args, q.MinParty)
+	}
+	if q.MaxParty != 0 {
+		where, args = joinFilters(where, "accompanying_guests + 1 <= ?"), append(args, q.MaxParty)
+	}
+	if !q.ArrivedAfter.IsZero() {
+		where, args = joinFilters(where, "time_arrived > ?"), append(args, s.timeArg(q.ArrivedAfter))
+	}
+
+	return where, args
+}
+
+// Condition and arguments selecting the guests after cursor (c) in its sort order
+func (s *sqlStore) afterCursor(c GuestCursor) (string, []interface{}) {
+	switch c.Sort {
+	case "name":
+		return "(guest_name > ? OR (guest_name = ? AND id > ?))", []interface{}{c.Key, c.Key, c.ID}
+	case "table":
+		table, _ := strconv.Atoi(c.Key)
+		return "(table_number > ? OR (table_number = ? AND id > ?))", []interface{}{table, table, c.ID}
+	case "time_arrived":
+		// guests yet to arrive come last
+		if c.Key == "" {
+			return "(time_arrived IS NULL AND id > ?)", []interface{}{c.ID}
+		}
+		t, _ := time.Parse(time.RFC3339, c.Key)
+		return "(time_arrived > ? OR (time_arrived = ? AND id > ?) OR time_arrived IS NULL)", []interface{}{s.timeArg(t), s.timeArg(t), c.ID}
+	}
+
+	return "id > ?", []interface{}{c.ID}
+}
+
+// Adds condition to WHERE clause (where)
+func joinFilters(where string, condition string) string {
+	if where == "" {
+		return " WHERE " + condition
+	}
+
+	return where + " AND " + condition
+}
+
+// Argument comparing with timestamp columns
+func (s *sqlStore) timeArg(t time.Time) interface{} {
+	if s.dialect.timeLayout != "" {
+		return t.UTC().Format(s.dialect.timeLayout)
+	}
+
+	return t.UTC()
+}
+
+// Calls fn with every guest (only the arrived ones if arrived = true) in the order they were added, a row at a time
+// so the guestlist is never held in memory whole. Stops at fn's first error and returns it
+func (s *sqlStore) StreamGuests(arrived bool, fn func(g Guest) error) error {
+	query, args := "SELECT "+guestColumns+" FROM guestlist ORDER BY id", []interface{}{}
+	if arrived {
+		query, args = "SELECT "+guestColumns+" FROM guestlist WHERE arrived = ? ORDER BY id", []interface{}{true}
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := s.scanGuest(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
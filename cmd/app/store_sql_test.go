@@ -0,0 +1,57 @@
+// store_sql_test.go
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Tests placeholder rewriting for dialects with numbered parameters
+func TestRebind(t *testing.T) {
+	query := "UPDATE guestlist SET accompanying_guests=?, arrived=? WHERE guest_name=?"
+
+	mysql := sqlStore{dialect: mysqlDialect}
+	if got := mysql.rebind(query); got != query {
+		t.Errorf("Expected query to be unchanged. Got '%s'", got)
+	}
+
+	postgres := sqlStore{dialect: postgresDialect}
+	expected := "UPDATE guestlist SET accompanying_guests=$1, arrived=$2 WHERE guest_name=$3"
+	if got := postgres.rebind(query); got != expected {
+		t.Errorf("Expected '%s'. Got '%s'", expected, got)
+	}
+}
+
+// Tests that constraints refused by each driver become model errors, wrapped or not
+func TestConstraintErrors(t *testing.T) {
+	cases := []struct {
+		dialect  dialect
+		err      error
+		expected error
+	}{
+		{mysqlDialect, &mysql.MySQLError{Number: 1062}, ErrDuplicateGuest},
+		{mysqlDialect, &mysql.MySQLError{Number: 1452}, ErrBrokenReference},
+		{mysqlDialect, &mysql.MySQLError{Number: 1213}, nil},
+		{postgresDialect, &pq.Error{Code: "23505"}, ErrDuplicateGuest},
+		{postgresDialect, fmt.Errorf("insert: %w", &pq.Error{Code: "23503"}), ErrBrokenReference},
+		{postgresDialect, errors.New("connection reset"), nil},
+	}
+
+	for _, c := range cases {
+		s := sqlStore{dialect: c.dialect}
+
+		expected := c.expected
+		if expected == nil {
+			expected = c.err // left as it is
+		}
+
+		if got := s.modelError(c.err); got != expected {
+			t.Errorf("%s: expected %v for %v. Got %v", c.dialect.name, expected, c.err, got)
+		}
+	}
+}
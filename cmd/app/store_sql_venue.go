@@ -0,0 +1,273 @@
+// store_sql_venue.go
+
+package main
+
+import (
+	"database/sql"
+)
+
+// Lists every venue table with its free seats
+func (s *sqlStore) GetTables() ([]Table, error) {
+	return s.tables(s.db, false)
+}
+
+// Gets venue table (table) with its free seats
+func (s *sqlStore) GetTable(table int) (Table, error) {
+	t := Table{Number: table}
+	var err error
+
+	if t.Seats, err = s.tableSeats(s.db, table, false); err != nil {
+		return t, err
+	}
+
+	used, err := s.usedSeats(s.db, table)
+	t.SeatsEmpty = t.Seats - used
+
+	return t, err
+}
+
+// Changes the seats of table, refusing to go below the seats already taken
+func (s *sqlStore) ResizeTable(table int, seats int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.tableSeats(tx, table, true); err != nil {
+			return err
+		}
+
+		used, err := s.usedSeats(tx, table)
+		if err != nil {
+			return err
+		}
+
+		if seats < used {
+			return ErrSeatsBelowOccupancy
+		}
+
+		_, err = tx.Exec(s.rebind("UPDATE venue SET seats=? WHERE table_number=?"), seats, table)
+		return err
+	})
+}
+
+// Removes table from the venue
+// Its guests are moved to other tables with free seats if reassign = true, otherwise the table must be empty
+func (s *sqlStore) DeleteTable(table int, reassign bool) ([]Guest, error) {
+	moved := []Guest{}
+
+	err := s.withTx(func(tx *sql.Tx) error {
+
+		// every table may receive guests, lock them all in the same order
+		tables, err := s.tables(tx, true)
+		if err != nil {
+			return err
+		}
+
+		free := map[int]int{}
+		others := []int{}
+		found := false
+
+		for _, t := range tables {
+			if t.Number == table {
+				found = true
+				continue
+			}
+			free[t.Number] = t.SeatsEmpty
+			others = append(others, t.Number)
+		}
+
+		if !found {
+			return ErrTableNotFound
+		}
+
+		// departed guests stay on the guestlist with the removed table
+		guests, err := s.guestsAt(tx, table)
+		if err != nil {
+			return err
+		}
+
+		if len(guests) > 0 && !reassign {
+			return ErrTableOccupied
+		}
+
+		if moved, err = firstFit(guests, others, free); err != nil {
+			return err
+		}
+
+		for _, g := range moved {
+			if _, err := tx.Exec(s.rebind("UPDATE guestlist SET table_number=? WHERE id=?"), g.Table, g.ID); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(s.rebind("DELETE FROM venue WHERE table_number=?"), table)
+		return err
+	})
+
+	return moved, err
+}
+
+// Gets table (every table if 0) and the guests seated there, with their arrival and departure, in one transaction
+func (s *sqlStore) GetSeating(table int) ([]Table, []Guest, error) {
+	var tables []Table
+	guests := []Guest{}
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		all, err := s.tables(tx, false)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range all {
+			if table == 0 || t.Number == table {
+				tables = append(tables, t)
+			}
+		}
+
+		if len(tables) == 0 && table != 0 {
+			return ErrTableNotFound
+		}
+
+		guests, err = s.seatedGuests(tx, table)
+		return err
+	})
+
+	if tables == nil {
+		tables = []Table{}
+	}
+
+	return tables, guests, err
+}
+
+// Moves guests as planned (guest id -> table) on a snapshot of every table and guest, all tables locked meanwhile
+func (s *sqlStore) Reseat(plan func(tables []Table, guests []Guest) (map[int]int, error)) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		tables, err := s.tables(tx, true)
+		if err != nil {
+			return err
+		}
+
+		guests, err := s.seatedGuests(tx, 0)
+		if err != nil {
+			return err
+		}
+
+		moves, err := plan(tables, guests)
+		if err != nil {
+			return err
+		}
+
+		if err := checkMoves(tables, guests, moves); err != nil {
+			return err
+		}
+
+		for _, g := range guests {
+			if table, ok := moves[g.ID]; ok && table != g.Table {
+				if _, err := tx.Exec(s.rebind("UPDATE guestlist SET table_number=? WHERE id=?"), table, g.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Guests seated at table (every guest if 0), oldest first
+func (s *sqlStore) seatedGuests(q querier, table int) ([]Guest, error) {
+	guests := []Guest{}
+
+	query := "SELECT " + guestColumns + " FROM guestlist"
+	args := []interface{}{}
+	if table != 0 {
+		query += " WHERE table_number=?"
+		args = append(args, table)
+	}
+
+	rows, err := q.Query(s.rebind(query+" ORDER BY id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := s.scanGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, g)
+	}
+
+	return guests, rows.Err()
+}
+
+// Lists venue tables with their free seats, with lock = true every venue row stays locked until the transaction (q) ends
+func (s *sqlStore) tables(q querier, lock bool) ([]Table, error) {
+	seats := map[int]int{}
+	tables := []Table{}
+
+	query := "SELECT table_number, seats FROM venue ORDER BY table_number"
+	if lock {
+		query += s.dialect.forUpdate
+	}
+
+	rows, err := q.Query(s.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var t Table
+		if err := rows.Scan(&t.Number, &t.Seats); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// seats taken on each table
+	rows, err = q.Query(s.rebind("SELECT table_number, SUM(accompanying_guests + 1) FROM guestlist WHERE time_left IS NULL GROUP BY table_number"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, used int
+		if err := rows.Scan(&table, &used); err != nil {
+			return nil, err
+		}
+		seats[table] = used
+	}
+
+	for i := range tables {
+		tables[i].SeatsEmpty = tables[i].Seats - seats[tables[i].Number]
+	}
+
+	return tables, rows.Err()
+}
+
+// Guests seated at table, not those who left: they keep the table as history, even once it's removed
+func (s *sqlStore) guestsAt(q querier, table int) ([]Guest, error) {
+	guests := []Guest{}
+
+	rows, err := q.Query(s.rebind("SELECT id, guest_name, table_number, accompanying_guests, time_left FROM guestlist WHERE table_number=? AND time_left IS NULL ORDER BY id"), table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g Guest
+		var timeLeft sql.NullString
+		if err := rows.Scan(&g.ID, &g.Name, &g.Table, &g.AccompanyingGuests, &timeLeft); err != nil {
+			return nil, err
+		}
+		g.TimeLeft = timeLeft.String
+		guests = append(guests, g)
+	}
+
+	return guests, rows.Err()
+}
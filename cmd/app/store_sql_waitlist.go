@@ -0,0 +1,177 @@
+// store_sql_waitlist.go
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Columns read by scanEntry
+const waitlistColumns = "id, guest_name, table_number, accompanying_guests, priority, unique_name, requested_at, promoted_at, guest_id"
+
+// Queues guest (e) waiting for seats, refusing names already on the guestlist if e.Unique, sets e.ID
+func (s *sqlStore) AddToWaitlist(e *WaitlistEntry) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if e.Table != 0 {
+			if _, err := s.tableSeats(tx, e.Table, false); err != nil {
+				return err
+			}
+		}
+
+		if e.Unique {
+			var count int
+			if err := tx.QueryRow(s.rebind("SELECT COUNT(*) FROM guestlist WHERE guest_name = ?"), e.Name).Scan(&count); err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrDuplicateGuest
+			}
+		}
+
+		var err error
+		e.ID, err = s.insert(tx, "INSERT INTO waitlist (guest_name, table_number, accompanying_guests, priority, unique_name, requested_at) values (?, ?, ?, ?, ?, "+s.dialect.now+")",
+			e.Name, e.Table, e.AccompanyingGuests, e.Priority, e.Unique)
+		if err != nil {
+			return err
+		}
+
+		// requested_at is set by the database
+		stored, err := s.scanEntry(tx.QueryRow(s.rebind("SELECT "+waitlistColumns+" FROM waitlist WHERE id = ?"), e.ID))
+		*e = stored
+
+		return err
+	})
+}
+
+// Gets the entries still waiting, highest priority first, or the promoted ones by promotion time
+func (s *sqlStore) GetWaitlist(promoted bool) ([]WaitlistEntry, error) {
+	query := "SELECT " + waitlistColumns + " FROM waitlist WHERE promoted_at IS NULL ORDER BY priority DESC, id"
+	if promoted {
+		query = "SELECT " + waitlistColumns + " FROM waitlist WHERE promoted_at IS NOT NULL ORDER BY promoted_at, id"
+	}
+
+	return s.entries(s.db, query)
+}
+
+// Drops waiting entry (id), promoted entries are kept as a record
+func (s *sqlStore) RemoveFromWaitlist(id int) error {
+	res, err := s.db.Exec(s.rebind("DELETE FROM waitlist WHERE id = ? AND promoted_at IS NULL"), id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrEntryNotFound
+	}
+
+	return nil
+}
+
+// Adds every waiting entry that fits to the guestlist, highest priority first, with every table locked
+// Entries that don't fit (or whose name was taken meanwhile) keep waiting, no table is locked if nobody waits
+func (s *sqlStore) PromoteWaitlist() ([]WaitlistEntry, error) {
+	promoted := []WaitlistEntry{}
+
+	// most requests freeing seats find nobody waiting
+	var id int
+	switch err := s.db.QueryRow("SELECT id FROM waitlist WHERE promoted_at IS NULL LIMIT 1").Scan(&id); err {
+	case nil:
+	case sql.ErrNoRows:
+		return promoted, nil
+	default:
+		return nil, err
+	}
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		tables, err := s.tables(tx, true)
+		if err != nil {
+			return err
+		}
+
+		waiting, err := s.entries(tx, "SELECT "+waitlistColumns+" FROM waitlist WHERE promoted_at IS NULL ORDER BY priority DESC, id")
+		if err != nil {
+			return err
+		}
+
+		for _, e := range waiting {
+			g := Guest{Name: e.Name, Table: e.Table, AccompanyingGuests: e.AccompanyingGuests}
+			if g.Table == 0 {
+				if g.Table = pickBestFit(tables, g.AccompanyingGuests+1); g.Table == 0 {
+					continue
+				}
+			}
+
+			switch err := s.addGuest(tx, &g, e.Unique); {
+			case err == nil:
+			case errors.Is(err, ErrTableFull), errors.Is(err, ErrTableNotFound), errors.Is(err, ErrDuplicateGuest):
+				continue
+			default:
+				return err
+			}
+
+			if _, err := tx.Exec(s.rebind("UPDATE waitlist SET table_number=?, guest_id=?, promoted_at="+s.dialect.now+" WHERE id=?"), g.Table, g.ID, e.ID); err != nil {
+				return err
+			}
+
+			for i := range tables {
+				if tables[i].Number == g.Table {
+					tables[i].SeatsEmpty -= g.AccompanyingGuests + 1
+				}
+			}
+
+			stored, err := s.scanEntry(tx.QueryRow(s.rebind("SELECT "+waitlistColumns+" FROM waitlist WHERE id = ?"), e.ID))
+			if err != nil {
+				return err
+			}
+			promoted = append(promoted, stored)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, nil
+}
+
+// Waitlist rows selected by query (with waitlistColumns)
+func (s *sqlStore) entries(q querier, query string) ([]WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+
+	rows, err := q.Query(s.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := s.scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Reads a waitlist row selected with waitlistColumns
+func (s *sqlStore) scanEntry(row interface{ Scan(dest ...interface{}) error }) (WaitlistEntry, error) {
+	var e WaitlistEntry
+	var promotedAt sql.NullString
+	var guestID sql.NullInt64
+
+	if err := row.Scan(&e.ID, &e.Name, &e.Table, &e.AccompanyingGuests, &e.Priority, &e.Unique, &e.RequestedAt, &promotedAt, &guestID); err != nil {
+		return e, err
+	}
+
+	e.PromotedAt = promotedAt.String
+	e.GuestID = int(guestID.Int64)
+
+	return e, nil
+}
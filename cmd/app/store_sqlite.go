@@ -0,0 +1,40 @@
+// store_sqlite.go
+
+package main
+
+import (
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // requires cgo
+)
+
+// SQLite flavour of SQL
+// There is no SELECT ... FOR UPDATE, transactions take the database write lock when they begin (_txlock=immediate)
+var sqliteDialect = dialect{
+	name:   "sqlite",
+	driver: "sqlite3",
+	now:    "CURRENT_TIMESTAMP",
+
+	// the write lock is already held, the row only keeps the schema the same as the other backends
+	lockName: "INSERT OR IGNORE INTO guest_name_locks (guest_name) VALUES (?)",
+
+	// CURRENT_TIMESTAMP is stored as UTC text
+	timeLayout: "2006-01-02 15:04:05",
+
+	constraintError: sqliteConstraintError,
+}
+
+// Builds the sqlite data source for the database file (path), enabling foreign keys and write-locking transactions
+func sqliteDataSource(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return "file:" + strings.TrimPrefix(path, "file:") + separator + "_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"
+}
+
+// Opens (creating it if needed) the sqlite database file at path
+func newSQLiteStore(path string) (*sqlStore, error) {
+	return newSQLStore(sqliteDialect, sqliteDataSource(path))
+}
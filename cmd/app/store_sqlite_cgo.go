@@ -0,0 +1,29 @@
+// store_sqlite_cgo.go
+
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Model error for the SQLite UNIQUE and FOREIGN KEY constraint errors
+func sqliteConstraintError(err error) error {
+	var e sqlite3.Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	switch e.ExtendedCode {
+	case sqlite3.ErrConstraintUnique:
+		return ErrDuplicateGuest
+	case sqlite3.ErrConstraintForeignKey:
+		return ErrBrokenReference
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+// store_sqlite_cgo_test.go
+
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Tests that the constraints SQLite refuses become model errors
+func TestSQLiteConstraintErrors(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected error
+	}{
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, ErrDuplicateGuest},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, ErrBrokenReference},
+		{errors.New("disk I/O error"), nil},
+	}
+
+	s := sqlStore{dialect: sqliteDialect}
+
+	for _, c := range cases {
+		expected := c.expected
+		if expected == nil {
+			expected = c.err // left as it is
+		}
+
+		if got := s.modelError(c.err); got != expected {
+			t.Errorf("expected %v for %v. Got %v", expected, c.err, got)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// store_sqlite_nocgo.go
+
+//go:build !cgo
+// +build !cgo
+
+package main
+
+// Without cgo the SQLite driver is a stub that can't open a database, there are no constraint errors to map
+func sqliteConstraintError(err error) error {
+	return nil
+}
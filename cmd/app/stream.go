@@ -0,0 +1,158 @@
+// stream.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Size of the buffer in front of a streamed response, it goes out to the client whenever it fills
+const streamBufferSize = 32 << 10
+
+// Writes a streamed guest listing in one format
+type guestEncoder interface {
+	begin(w io.Writer) error          // before the first guest
+	guest(w io.Writer, g Guest) error // for each guest
+	end(w io.Writer) error            // after the last guest
+}
+
+// Writes the guests (only the arrived ones if arrived = true) to the response as the store reads them,
+// memory stays the same whatever the size of the guest list.
+// Nothing goes out before the first guest is read, so a failing store still answers http.StatusInternalServerError;
+// once the status is sent a failure can only cut the response short
+func (a *App) streamGuests(w http.ResponseWriter, arrived bool, contentType string, enc guestEncoder) {
+	body := bufio.NewWriterSize(w, streamBufferSize)
+	started := false
+
+	// sends the status ahead of the body
+	start := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		started = true
+
+		return enc.begin(body)
+	}
+
+	err := a.Store.StreamGuests(arrived, func(g Guest) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		return enc.guest(body, g)
+	})
+
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end(body)
+	}
+	if err == nil {
+		err = body.Flush()
+	}
+
+	switch {
+	case err != nil && !started:
+		w.Header().Del("Content-Disposition")
+		respondWithProblem(w, err)
+	case err != nil:
+		log.Printf("streaming guests: response cut short: %v", err)
+	}
+}
+
+// Fields of guest (g) listed on GET /guest_list
+func listedGuest(g Guest) Guest {
+	return Guest{Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests}
+}
+
+// Fields of guest (g) listed on GET /guests
+func arrivedGuest(g Guest) Guest {
+	return Guest{Name: g.Name, Table: g.Table, TimeArrived: g.TimeArrived}
+}
+
+// Writes the GuestList envelope, the same bytes as respondWithJSON, with each guest cut down by fields
+type guestListEncoder struct {
+	fields  func(g Guest) Guest
+	written int
+	buf     bytes.Buffer  // reused for every guest
+	encoder *json.Encoder // writes to buf
+}
+
+func (e *guestListEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, `{"guests":[`)
+	return err
+}
+
+func (e *guestListEncoder) guest(w io.Writer, g Guest) error {
+	if e.written > 0 {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+
+	if e.encoder == nil {
+		e.encoder = json.NewEncoder(&e.buf)
+	}
+
+	e.buf.Reset()
+	if err := e.encoder.Encode(e.fields(g)); err != nil {
+		return err
+	}
+	e.written++
+
+	// without the newline Encode ends with
+	_, err := w.Write(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")))
+	return err
+}
+
+func (e *guestListEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, "]}")
+	return err
+}
+
+// Writes the CSV export, a header row and a row per guest
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func (e *csvEncoder) begin(w io.Writer) error {
+	e.writer = csv.NewWriter(w)
+	return e.writer.Write(exportColumns)
+}
+
+func (e *csvEncoder) guest(w io.Writer, g Guest) error {
+	r := exportRow(g)
+	return e.writer.Write([]string{strconv.Itoa(r.ID), r.Name, strconv.Itoa(r.Table), strconv.Itoa(r.AccompanyingGuests), strconv.FormatBool(r.Arrived), strconv.Itoa(r.Present), r.TimeArrived, r.TimeLeft})
+}
+
+func (e *csvEncoder) end(w io.Writer) error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// Writes the JSON Lines export, a guest per line
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlEncoder) begin(w io.Writer) error {
+	e.encoder = json.NewEncoder(w)
+	return nil
+}
+
+func (e *jsonlEncoder) guest(w io.Writer, g Guest) error {
+	return e.encoder.Encode(exportRow(g))
+}
+
+func (e *jsonlEncoder) end(w io.Writer) error {
+	return nil
+}
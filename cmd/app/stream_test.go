@@ -0,0 +1,157 @@
+// stream_test.go
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Tests that the streamed listings are the same bytes the whole lists marshal to
+func TestStreamGuestList(t *testing.T) {
+	initializeDB()
+	addGuests(20, true)
+
+	for path, get := range map[string]func() (GuestList, error){"/guest_list": a.Store.GetGuestList, "/guests": a.Store.GetArrivedGuests} {
+		gl, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := json.Marshal(gl)
+
+		req, _ := http.NewRequest("GET", path, nil)
+		response := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		if body := response.Body.String(); body != string(expected) {
+			t.Errorf("%s: expected %s. Got %s", path, expected, body)
+		}
+		if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s: expected application/json. Got '%s'", path, contentType)
+		}
+	}
+}
+
+// Store whose streams fail before the first guest
+type failingStreamStore struct {
+	Store
+}
+
+func (s failingStreamStore) StreamGuests(arrived bool, fn func(g Guest) error) error {
+	return errors.New("connection lost")
+}
+
+// Tests that a stream failing before the first guest still answers an error
+func TestStreamGuestListError(t *testing.T) {
+	app := App{Store: failingStreamStore{newMemoryStore()}}
+
+	for _, format := range []string{"json", "csv"} {
+		req, _ := http.NewRequest("GET", "/guest_list?format="+format, nil)
+		response := httptest.NewRecorder()
+		app.handlerGuestList(response, req)
+
+		checkResponseCode(t, http.StatusInternalServerError, response.Code)
+		if body := response.Body.String(); body != `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal error","code":"internal_error"}` {
+			t.Errorf("Expected an internal_error problem, without the store's error. Got '%s'", body)
+		}
+		if disposition := response.Header().Get("Content-Disposition"); disposition != "" {
+			t.Errorf("Expected no attachment. Got '%s'", disposition)
+		}
+	}
+}
+
+// Response writer keeping only the sizes of the writes and when the first one came
+type measuringWriter struct {
+	header    http.Header
+	start     time.Time
+	firstByte time.Duration
+	largest   int
+}
+
+func (w *measuringWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *measuringWriter) WriteHeader(code int) {}
+
+func (w *measuringWriter) Write(p []byte) (int, error) {
+	if w.firstByte == 0 {
+		w.firstByte = time.Since(w.start)
+	}
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+
+	return len(p), nil
+}
+
+// Compares marshalling the whole guest list with streaming it, on 100k guests
+// largest-write-B is the most the response held at once, first-byte-ns how long the client waited for it
+// running: go test -run '^$' -bench GuestList -benchmem ./cmd/app (APP_BACKEND=sqlite for a database)
+func BenchmarkGuestList(b *testing.B) {
+	const count = 100000
+
+	resetDB()
+	defer resetDB()
+
+	a.Store.AddTable(count)
+
+	guests := make([]Guest, count)
+	for i := range guests {
+		guests[i] = Guest{Name: "BenchGuest" + strconv.Itoa(i), Table: 1}
+	}
+
+	// straight into the database, checking the seats for every row takes minutes
+	if db != nil {
+		err := db.withTx(func(tx *sql.Tx) error {
+			for _, g := range guests {
+				if _, err := tx.Exec(db.rebind("INSERT INTO guestlist (guest_name, table_number, accompanying_guests) VALUES (?, ?, ?)"), g.Name, g.Table, g.AccompanyingGuests); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	} else if _, err := a.Store.ImportGuests(guests, pickBestFit, false, importBestEffort); err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B, respond func(w http.ResponseWriter)) {
+		b.ReportAllocs()
+
+		var w *measuringWriter
+		for i := 0; i < b.N; i++ {
+			w = &measuringWriter{header: http.Header{}, start: time.Now()}
+			respond(w)
+		}
+
+		b.ReportMetric(float64(w.largest), "largest-write-B")
+		b.ReportMetric(float64(w.firstByte.Nanoseconds()), "first-byte-ns")
+	}
+
+	b.Run("buffered", func(b *testing.B) {
+		run(b, func(w http.ResponseWriter) {
+			gl, err := a.Store.GetGuestList()
+			if err != nil {
+				b.Fatal(err)
+			}
+			respondWithJSON(w, http.StatusOK, gl)
+		})
+	})
+
+	b.Run("streamed", func(b *testing.B) {
+		req, _ := http.NewRequest("GET", "/guest_list", nil)
+
+		run(b, func(w http.ResponseWriter) {
+			a.handlerGuestList(w, req)
+		})
+	})
+}
@@ -0,0 +1,166 @@
+// venue.go
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+### List tables
+
+GET /venue
+response:
+{
+	"tables": [
+		{
+			"table_number": int,
+			"seats": int,
+			"seats_empty": int
+		}, ...
+	]
+}
+*/
+func (a *App) handlerGetTables(w http.ResponseWriter, r *http.Request) {
+
+	tables, err := a.Store.GetTables()
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Venue{Tables: tables})
+}
+
+/*
+### Get a table
+
+Throws http.StatusNotFound if there's no such table.
+
+GET /venue/table
+response:
+{
+	"table_number": int,
+	"seats": int,
+	"seats_empty": int
+}
+*/
+func (a *App) handlerGetTable(w http.ResponseWriter, r *http.Request) {
+
+	table, _ := strconv.Atoi(mux.Vars(r)["table"]) // route only matches digits
+
+	t, err := a.Store.GetTable(table)
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, t)
+}
+
+/*
+### Change the seats of a table
+
+Shrinking a table below the seats already taken by its guests throws an error (http.StatusConflict).
+Tables keep at least a seat, seats must be positive (http.StatusBadRequest otherwise).
+
+PATCH /venue/table
+body:
+{
+	"seats": int
+}
+response:
+{
+	"table_number": int,
+	"seats": int,
+	"seats_empty": int
+}
+*/
+func (a *App) handlerResizeTable(w http.ResponseWriter, r *http.Request) {
+
+	table, _ := strconv.Atoi(mux.Vars(r)["table"]) // route only matches digits
+
+	// Temporary struct used for decoding
+	seats := struct {
+		S *int `json:"seats"`
+	}{}
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&seats); err != nil {
+		respondWithProblem(w, invalidPayload(err))
+		return
+	}
+	defer r.Body.Close()
+
+	if seats.S == nil || *seats.S <= 0 {
+		respondWithProblem(w, invalidParam("seats", "seats must be a positive number"))
+		return
+	}
+
+	if err := a.Store.ResizeTable(table, *seats.S); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	a.promoteWaitlist()
+
+	t, err := a.Store.GetTable(table)
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, t)
+}
+
+/*
+### Remove a table
+
+By default a table with seated guests can't be removed (http.StatusConflict).
+With ?guests=reassign its guests are moved to the first tables with enough free seats,
+the biggest parties first. If they don't all fit nothing changes (http.StatusConflict).
+Guests who already left aren't seated: they stay on the guest list with the removed table.
+
+DELETE /venue/table?guests=refuse|reassign
+response:
+{
+	"result": "success",
+	"reassigned": [
+		{
+			"name": "string",
+			"table": int,
+			"accompanying_guests": int
+		}, ...
+	]
+}
+*/
+func (a *App) handlerDeleteTable(w http.ResponseWriter, r *http.Request) {
+
+	table, _ := strconv.Atoi(mux.Vars(r)["table"]) // route only matches digits
+
+	var reassign bool
+	switch r.URL.Query().Get("guests") {
+	case "", "refuse":
+	case "reassign":
+		reassign = true
+	default:
+		respondWithProblem(w, invalidParam("guests", "guests must be refuse or reassign"))
+		return
+	}
+
+	moved, err := a.Store.DeleteTable(table, reassign)
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"result": "success", "reassigned": moved})
+}
@@ -0,0 +1,124 @@
+// waitlist.go
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Waitlist options of the add guest endpoints: ?waitlist=true queues guests turned away, with ?priority= (0 if not given)
+func requestWaitlist(r *http.Request) (bool, int, bool) {
+	queue := r.URL.Query().Get("waitlist") == "true"
+
+	param := r.URL.Query().Get("priority")
+	if param == "" {
+		return queue, 0, true
+	}
+
+	priority, err := strconv.Atoi(param)
+
+	return queue, priority, err == nil
+}
+
+// Queues guest (g), turned away for lack of seats, answering http.StatusAccepted with the entry
+// The entry comes back already promoted if seats freed up before it was queued
+func (a *App) queueGuest(w http.ResponseWriter, g Guest, priority int, unique bool) {
+	e := WaitlistEntry{Name: g.Name, Table: g.Table, AccompanyingGuests: g.AccompanyingGuests, Priority: priority, Unique: unique}
+
+	if err := a.Store.AddToWaitlist(&e); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	// the promotion run by whoever freed them may have found the waitlist still empty
+	for _, promoted := range a.promoteWaitlist() {
+		if promoted.ID == e.ID {
+			e = promoted
+		}
+	}
+
+	respondWithJSON(w, http.StatusAccepted, e)
+}
+
+// Promotes waiting guests after seats free up, logging every promotion, returns the promoted entries
+// Failures are only logged, the request that freed the seats already succeeded
+func (a *App) promoteWaitlist() []WaitlistEntry {
+	promoted, err := a.Store.PromoteWaitlist()
+	if err != nil {
+		log.Printf("waitlist: promotion failed: %v", err)
+		return nil
+	}
+
+	for _, e := range promoted {
+		log.Printf("waitlist: promoted entry %d %q (priority %d, party of %d) to table %d as guest %d", e.ID, e.Name, e.Priority, e.AccompanyingGuests+1, e.Table, e.GuestID)
+	}
+
+	return promoted
+}
+
+/*
+### Get the waitlist
+
+Guests turned away for lack of seats, added with ?waitlist=true on POST /guest_list/name or POST /v2/guests.
+Entries still waiting are listed highest priority first, then by request.
+With ?promoted=true lists the entries already promoted to the guestlist instead, with their table and guest id.
+
+GET /waitlist?promoted=true
+response:
+{
+	"waitlist": [
+		{
+			"id": int,
+			"name": "string",
+			"table": int,
+			"accompanying_guests": int,
+			"priority": int,
+			"requested_at": "string",
+			"promoted_at": "string",
+			"guest_id": int
+		}, ...
+	]
+}
+*/
+func (a *App) handlerGetWaitlist(w http.ResponseWriter, r *http.Request) {
+
+	entries, err := a.Store.GetWaitlist(r.URL.Query().Get("promoted") == "true")
+
+	if err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Waitlist{Entries: entries})
+}
+
+/*
+### Remove a waitlist entry
+
+Drops an entry still waiting (http.StatusNotFound if there's none with that id).
+
+DELETE /waitlist/id
+response:
+{
+	"result": "success"
+}
+*/
+func (a *App) handlerRemoveFromWaitlist(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		respondWithProblem(w, invalidParam("id", "Invalid waitlist entry id"))
+		return
+	}
+
+	if err := a.Store.RemoveFromWaitlist(id); err != nil {
+		respondWithProblem(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}